@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/accuracy"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// runAccuracy compares machine suggestions against the human labels of an
+// exported report
+func runAccuracy(args []string) {
+	fs := flag.NewFlagSet("accuracy", flag.ExitOnError)
+	inPath := fs.String("in", "report.json", "path to a labeled report exported from the HTML page")
+	target := fs.String("target", accuracy.TargetMatch, "label to evaluate against: match or similar (match or similar ticked)")
+	threshold := fs.Float64("threshold", 0.8, "similarity score at which a candidate is suggested")
+	thresholds := fs.String("thresholds", "", "comma separated thresholds for the precision/recall table")
+	worst := fs.Int("worst", 20, "number of worst disagreements to list")
+	htmlOut := fs.String("html", "", "also write an HTML report with images to this path")
+	productionPath := fs.String("production", "", "the report as generated, before labeling, to evaluate the production flags of its local column")
+	logOpts := addLogFlags(fs)
+	fs.Parse(args)
+	if err := logOpts.setup(os.Stderr); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+
+	opts := accuracy.Options{Target: *target, Threshold: *threshold, Worst: *worst}
+	if *thresholds != "" {
+		for _, s := range strings.Split(*thresholds, ",") {
+			t, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				fatal("invalid threshold", "threshold", s, "error", err)
+			}
+			opts.Thresholds = append(opts.Thresholds, t)
+		}
+	}
+
+	comparisons, err := report.LoadJSONReport(*inPath)
+	if err != nil {
+		fatal("failed to load report", "error", err)
+	}
+
+	if *productionPath != "" {
+		if opts.Production, err = report.LoadJSONReport(*productionPath); err != nil {
+			fatal("failed to load production report", "error", err)
+		}
+	}
+
+	res, err := accuracy.Evaluate(comparisons, opts)
+	if err != nil {
+		fatal("failed to evaluate labels", "error", err)
+	}
+	if err := accuracy.WriteText(os.Stdout, res); err != nil {
+		fatal("failed to write summary", "error", err)
+	}
+
+	if *htmlOut != "" {
+		if err := accuracy.GenerateHTMLReport(res, *htmlOut); err != nil {
+			fatal("failed to generate accuracy report", "error", err)
+		}
+		slog.Info("accuracy report saved", "out", *htmlOut)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// Files of a run directory
+const (
+	runMetaFile    = "run.json"          // envelope of the run, including its command line
+	checkpointFile = "checkpoint.ndjson" // every completed product, appended as it finishes
+)
+
+// checkpoint records completed products in a run directory so an interrupted
+// run can resume without fetching them again
+type checkpoint struct {
+	dir  string
+	w    *report.NDJSONWriter
+	done map[string]report.Report // reusable reports by report.Key
+}
+
+// openCheckpoint prepares a run directory. When resuming, products completed
+// without a failed provider call are kept; failed ones are fetched again.
+func openCheckpoint(dir string, resume bool) (*checkpoint, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create run directory %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, checkpointFile)
+	c := &checkpoint{dir: dir, done: make(map[string]report.Report)}
+
+	if !resume {
+		w, err := report.CreateNDJSON(path)
+		if err != nil {
+			return nil, err
+		}
+		c.w = w
+		return c, nil
+	}
+
+	reports, err := report.LoadJSONReport(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, r := range reports {
+		if r.Failed() {
+			delete(c.done, r.Key())
+			continue
+		}
+		c.done[r.Key()] = r
+	}
+	if c.w, err = report.AppendNDJSON(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reuse returns the checkpointed report of a product that already completed
+func (c *checkpoint) reuse(prod model.SuggestionProduct) (report.Report, bool) {
+	r, ok := c.done[productKey(prod)]
+	return r, ok
+}
+
+// record appends a freshly fetched report
+func (c *checkpoint) record(res comparisonResult) error {
+	if res.reused {
+		return nil
+	}
+	return c.w.Write(res.report)
+}
+
+// writeMeta saves the envelope of the run, without reports
+func (c *checkpoint) writeMeta(env report.Envelope) error {
+	return report.GenerateJSONComparisonReport(env.Meta(), filepath.Join(c.dir, runMetaFile))
+}
+
+func (c *checkpoint) Close() error {
+	return c.w.Close()
+}
+
+// loadRunMeta reads the envelope saved in a run directory
+func loadRunMeta(dir string) (report.Envelope, error) {
+	return report.LoadRun(filepath.Join(dir, runMetaFile))
+}
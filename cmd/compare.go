@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/alihunter"
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/filter"
+	"github.com/quanghia24/letsgo/internal/httpclient"
+	"github.com/quanghia24/letsgo/internal/margin"
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/progress"
+	"github.com/quanghia24/letsgo/internal/ranking"
+	"github.com/quanghia24/letsgo/internal/rapidapi"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// compareOptions holds the settings shared by every product comparison
+type compareOptions struct {
+	weights  ranking.Weights // zero weights keep upstream order
+	filters  filter.Config
+	prices   map[int64]float64 // Shopify selling prices overriding the input field
+	shipping float64           // estimated shipping cost per candidate
+
+	// reuse returns an earlier report to keep instead of fetching the product again
+	reuse func(prod model.SuggestionProduct) (report.Report, bool)
+	// progress counts calls and finished products, nil when not displayed
+	progress *progress.Tracker
+}
+
+// sellingPrice returns the Shopify selling price of a product, preferring the
+// price file over the input field
+func (o compareOptions) sellingPrice(prod model.SuggestionProduct) float64 {
+	if price, ok := o.prices[prod.ProductID]; ok {
+		return price
+	}
+	return candidate.ParsePrice(prod.Product.Price)
+}
+
+// column describes how to handle the results of one provider
+type column[T any] struct {
+	provider   string
+	id         func(T) string
+	conv       func(T) candidate.Candidate
+	setReviews func(*T, string)
+	setScore   func(*T, float64)
+	search     func(ctx context.Context, imageURL string) ([]T, error)
+}
+
+// columnResult is what one provider produced for a product
+type columnResult[T any] struct {
+	top      []T
+	origin   []T
+	excluded []filter.Exclusion
+	status   report.ProviderStatus
+}
+
+var aliHunterColumn = column[model.AliHunterProduct]{
+	provider:   candidate.ProviderAliHunter,
+	id:         func(p model.AliHunterProduct) string { return p.ProductID },
+	conv:       candidate.FromAliHunter,
+	setReviews: func(p *model.AliHunterProduct, count string) { p.TotalReview = count },
+	setScore:   func(p *model.AliHunterProduct, score float64) { p.RankScore = score },
+	search:     alihunter.AliHunterSearchByImage,
+}
+
+var aliExpressColumn = column[model.AliExpressProduct]{
+	provider:   candidate.ProviderAliExpress,
+	id:         func(p model.AliExpressProduct) string { return p.ProductID },
+	conv:       candidate.FromAliExpress,
+	setReviews: func(p *model.AliExpressProduct, count string) { p.TotalReview = count },
+	setScore:   func(p *model.AliExpressProduct, score float64) { p.RankScore = score },
+	search:     rapidapi.AliExpressSearchByImage,
+}
+
+// columnWork is what a provider returned for a product, before it is scored
+type columnWork[T any] struct {
+	originals []T
+	err       error
+	latency   time.Duration
+}
+
+// scoreProduct builds the comparison row of a product from what both
+// providers returned
+func scoreProduct(prod model.SuggestionProduct, aliHunterWork columnWork[model.AliHunterProduct], aliExpressWork columnWork[model.AliExpressProduct], opts compareOptions) report.Report {
+	aliHunter := scoreColumn(aliHunterColumn, aliHunterWork, opts)
+	aliExpress := scoreColumn(aliExpressColumn, aliExpressWork, opts)
+
+	// Take top local products, re-ranked on a copy so the input stays untouched
+	setLocalScore := func(p *model.ProductItem, score float64) { p.RankScore = score }
+	localOrigin := append([]model.ProductItem(nil), prod.Products...)
+	localProducts, localExcluded := filter.Apply(localOrigin, candidate.FromLocal, opts.filters.For(candidate.ProviderLocal))
+	ranking.Sort(localProducts, candidate.FromLocal, opts.weights, setLocalScore)
+	ranking.Sort(localOrigin, candidate.FromLocal, opts.weights, setLocalScore)
+
+	comparison := report.Report{
+		SuggestionID:          suggestionID(prod),
+		ProductTitle:          prod.Product.Title,
+		ProductID:             prod.ProductID,
+		ImageURL:              prod.ImageURL,
+		ShopID:                prod.ShopID,
+		LocalRapidAPITop:      report.Top(localProducts),
+		LocalRapidAPIOrigin:   report.Top(localOrigin),
+		LocalRapidAPIExcluded: localExcluded,
+		LocalUnavailable:      prod.LocalUnavailable,
+		AliHunterTop:          aliHunter.top,
+		AliHunterOrigin:       aliHunter.origin,
+		AliHunterExcluded:     aliHunter.excluded,
+		AliHunterStatus:       aliHunter.status,
+		AliExpressTop:         aliExpress.top,
+		AliExpressOrigin:      aliExpress.origin,
+		AliExpressExcluded:    aliExpress.excluded,
+		AliExpressStatus:      aliExpress.status,
+		FetchedAt:             time.Now().UTC(),
+	}
+	// the production column comes with the input, there is no call to fail
+	if !prod.LocalUnavailable {
+		comparison.LocalRapidAPIStatus = report.NewProviderStatus(nil, len(prod.Products), len(localProducts), 0)
+	}
+	margin.Apply(&comparison, opts.sellingPrice(prod), opts.shipping)
+	return comparison
+}
+
+// fetchColumn queries one provider for a product and builds its columns. A
+// failed call leaves empty columns and an error status.
+func fetchColumn[T any](ctx context.Context, col column[T], prod model.SuggestionProduct, opts compareOptions, logger *slog.Logger) columnResult[T] {
+	work := searchColumn(ctx, col, prod, logger)
+	enrichColumn(ctx, col, work, opts, logger)
+	return scoreColumn(col, work, opts)
+}
+
+// searchColumn queries one provider for a product
+func searchColumn[T any](ctx context.Context, col column[T], prod model.SuggestionProduct, logger *slog.Logger) columnWork[T] {
+	start := time.Now()
+	originals, err := col.search(ctx, prod.ImageURL)
+	work := columnWork[T]{originals: originals, err: err, latency: time.Since(start)}
+	logger = logger.With("provider", col.provider, "latency", work.latency)
+	if err != nil && ctx.Err() != nil {
+		// the run is shutting down, the product is listed as unprocessed
+		logger.Debug("provider call cancelled", "error", err)
+	} else if err != nil {
+		if code := httpclient.StatusCode(err); code != 0 {
+			logger = logger.With("http_status", code)
+		}
+		logger.Warn("provider call failed", "error", err)
+	} else {
+		logger.Debug("provider call", "results", len(originals))
+	}
+	return work
+}
+
+// enrichColumn fills in the review counts scoring needs: of every candidate
+// when they feed the score or a rule, otherwise only of the candidates that
+// make it into a top column
+func enrichColumn[T any](ctx context.Context, col column[T], work columnWork[T], opts compareOptions, logger *slog.Logger) {
+	if work.err != nil || len(work.originals) == 0 {
+		return
+	}
+	if opts.weights.Reviews != 0 || opts.filters.For(col.provider).MinReviews > 0 {
+		fillReviews(ctx, col, work.originals, nil, logger)
+		return
+	}
+
+	// reviews don't change the order, so ranking a copy tells which
+	// candidates end up shown
+	products, originals, _ := rankColumns(col, append([]T(nil), work.originals...), opts)
+	shown := make(map[string]bool)
+	for _, list := range [][]T{products, originals} {
+		for _, p := range list {
+			shown[col.id(p)] = true
+		}
+	}
+	fillReviews(ctx, col, work.originals, func(id string) bool { return shown[id] }, logger)
+}
+
+// scoreColumn filters, ranks and cuts what a provider returned into its
+// columns. A failed call leaves empty columns and an error status.
+func scoreColumn[T any](col column[T], work columnWork[T], opts compareOptions) columnResult[T] {
+	if work.err != nil {
+		return columnResult[T]{top: []T{}, origin: []T{}, status: report.NewProviderStatus(work.err, 0, 0, work.latency)}
+	}
+	var res columnResult[T]
+	res.top, res.origin, res.excluded = rankColumns(col, work.originals, opts)
+	res.status = report.NewProviderStatus(nil, len(work.originals), len(res.top), work.latency)
+	return res
+}
+
+// refetchProvider replaces the columns of one provider in an existing report
+// with fresh results, leaving the other providers and their labels alone.
+// attempt counts the calls of the provider for this product, this one included.
+// When the call fails only the provider status changes, the earlier columns
+// and their labels stay. When ctx is cancelled during the call, the report is
+// left untouched.
+func refetchProvider(ctx context.Context, r *report.Report, provider string, attempt int, opts compareOptions) error {
+	prod := model.SuggestionProduct{ProductID: r.ProductID, ShopID: r.ShopID, ImageURL: normalizeImageURL(r.ImageURL)}
+	logger := productLogger(r.ShopID, r.ProductID, attempt)
+	switch provider {
+	case candidate.ProviderAliHunter:
+		res := fetchColumn(ctx, aliHunterColumn, prod, opts, logger)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if res.status.Failed() {
+			logger.Warn("keeping earlier results, fetching them again failed", "provider", provider, "error", res.status.Error)
+			r.AliHunterStatus = res.status
+			return nil
+		}
+		r.AliHunterTop, r.AliHunterOrigin, r.AliHunterExcluded, r.AliHunterStatus = res.top, res.origin, res.excluded, res.status
+	case candidate.ProviderAliExpress:
+		res := fetchColumn(ctx, aliExpressColumn, prod, opts, logger)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if res.status.Failed() {
+			logger.Warn("keeping earlier results, fetching them again failed", "provider", provider, "error", res.status.Error)
+			r.AliExpressStatus = res.status
+			return nil
+		}
+		r.AliExpressTop, r.AliExpressOrigin, r.AliExpressExcluded, r.AliExpressStatus = res.top, res.origin, res.excluded, res.status
+	default:
+		return fmt.Errorf("provider %q can't be fetched again, use %s or %s", provider, candidate.ProviderAliHunter, candidate.ProviderAliExpress)
+	}
+	margin.Apply(r, r.SellingPrice, opts.shipping)
+	return nil
+}
+
+// normalizeImageURL trims an image URL and gives protocol-relative URLs, as
+// Shopify CDN links often are, an https scheme the providers can fetch
+func normalizeImageURL(url string) string {
+	url = strings.TrimSpace(url)
+	if strings.HasPrefix(url, "//") {
+		url = "https:" + url
+	}
+	return url
+}
+
+// productKey identifies a suggestion product the way report.Key does
+func productKey(prod model.SuggestionProduct) string {
+	return report.Key(suggestionID(prod), prod.ShopID, prod.ProductID)
+}
+
+// suggestionID returns the hex _id of a suggestion, empty when unknown
+func suggestionID(prod model.SuggestionProduct) string {
+	if prod.ID.IsZero() {
+		return ""
+	}
+	return prod.ID.Hex()
+}
+
+// rankColumns filters a provider's full result list into the filtered
+// column, re-orders both columns and cuts them to the top candidates
+func rankColumns[T any](col column[T], originals []T, opts compareOptions) ([]T, []T, []filter.Exclusion) {
+	products, excluded := filter.Apply(originals, col.conv, opts.filters.For(col.provider))
+	ranking.Sort(products, col.conv, opts.weights, col.setScore)
+	ranking.Sort(originals, col.conv, opts.weights, col.setScore)
+	return report.Top(products), report.Top(originals), excluded
+}
+
+// fillReviews queries the total reviews of the candidates want accepts, or of
+// all when want is nil, once per product. A failed lookup leaves the count
+// as the provider sent it, usually unknown.
+func fillReviews[T any](ctx context.Context, col column[T], list []T, want func(id string) bool, logger *slog.Logger) {
+	counts := make(map[string]string)
+	for i := range list {
+		id := col.id(list[i])
+		if want != nil && !want(id) {
+			continue
+		}
+		count, ok := counts[id]
+		if !ok {
+			var err error
+			count, err = report.GetReviewsCount(ctx, id)
+			if err != nil {
+				logger.Warn("reviews lookup failed", "provider", col.provider, "candidate_id", id, "error", err)
+			}
+			counts[id] = count
+		}
+		if count != "" {
+			col.setReviews(&list[i], count)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/quanghia24/letsgo/internal/diff"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// runDiff reports what changed between two report.json files
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	htmlOut := fs.String("html", "", "also write an HTML diff page to this path")
+	logOpts := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: diff [-html diff.html] <old report.json> <new report.json>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := logOpts.setup(os.Stderr); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+	oldPath, newPath := fs.Arg(0), fs.Arg(1)
+
+	oldReports, err := report.LoadJSONReport(oldPath)
+	if err != nil {
+		fatal("failed to load old report", "error", err)
+	}
+	newReports, err := report.LoadJSONReport(newPath)
+	if err != nil {
+		fatal("failed to load new report", "error", err)
+	}
+
+	res := diff.Compare(oldReports, newReports)
+	res.OldPath, res.NewPath = oldPath, newPath
+	if err := diff.WriteText(os.Stdout, res); err != nil {
+		fatal("failed to write diff", "error", err)
+	}
+
+	if *htmlOut != "" {
+		if err := diff.GenerateHTMLReport(res, *htmlOut); err != nil {
+			fatal("failed to generate diff page", "error", err)
+		}
+		slog.Info("diff page saved", "out", *htmlOut)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/alihunter"
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/filter"
+	"github.com/quanghia24/letsgo/internal/input"
+	"github.com/quanghia24/letsgo/internal/ranking"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// newRunEnvelope records what a generate run is about to do
+func newRunEnvelope(source report.InputInfo, opts compareOptions) (report.Envelope, error) {
+	env := report.NewEnvelope()
+	env.Args = os.Args[1:]
+	env.Input = source
+	if source.Source == "file" {
+		sum, err := input.FileSHA256(source.Path)
+		if err != nil {
+			return env, err
+		}
+		env.Input.SHA256 = sum
+	}
+	env.Providers = providerConfigs()
+	if !opts.weights.IsZero() {
+		env.Rank = opts.weights.String()
+	}
+	env.Filters = &opts.filters
+	env.Shipping = opts.shipping
+	return env, nil
+}
+
+// providerConfigs lists the provider endpoints with secrets redacted
+func providerConfigs() []report.ProviderConfig {
+	rapid := configs.GetRapidAPIConfig()
+	return []report.ProviderConfig{
+		{Name: "local", Endpoint: "input"},
+		{Name: candidate.ProviderAliHunter, Endpoint: alihunter.ServiceURL},
+		{Name: candidate.ProviderAliExpress, Endpoint: "https://" + rapid.Host + "/item_search_image", APIKey: configs.Redact(rapid.APIKey)},
+	}
+}
+
+// optionsFromEnvelope rebuilds the comparison settings a run was made with,
+// so patching a report keeps its filters, ranking and shipping cost
+func optionsFromEnvelope(env report.Envelope) (compareOptions, error) {
+	weights, err := ranking.ParseWeights(env.Rank)
+	if err != nil {
+		return compareOptions{}, fmt.Errorf("invalid ranking weights %q: %w", env.Rank, err)
+	}
+	filters := filter.DefaultConfig
+	if env.Filters != nil {
+		filters = *env.Filters
+	}
+	return compareOptions{weights: weights, filters: filters, shipping: env.Shipping}, nil
+}
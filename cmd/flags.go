@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/httpclient"
+	"github.com/quanghia24/letsgo/internal/input"
+)
+
+// addCallTimeoutFlag registers -call-timeout on the commands that call the
+// providers
+func addCallTimeoutFlag(fs *flag.FlagSet) {
+	fs.DurationVar(&httpclient.Client.Timeout, "call-timeout", httpclient.Client.Timeout, "give up on a provider call after this long, counting it as a timeout (0 for no limit)")
+}
+
+// parseSelection builds an input selection from the selection flags. Shops
+// may be given by numeric ID or myshopify domain.
+func parseSelection(shops, jobs, statuses, since, until string, limit int) (input.Selection, error) {
+	sel := input.Selection{
+		JobIDs:   splitList(jobs),
+		Statuses: splitList(statuses),
+		Limit:    limit,
+	}
+	for _, shop := range splitList(shops) {
+		if id, err := strconv.ParseInt(shop, 10, 64); err == nil {
+			sel.ShopIDs = append(sel.ShopIDs, id)
+		} else {
+			sel.Domains = append(sel.Domains, shop)
+		}
+	}
+	var err error
+	if sel.CreatedFrom, err = parseTime(since); err != nil {
+		return sel, err
+	}
+	if sel.CreatedTo, err = parseTime(until); err != nil {
+		return sel, err
+	}
+	if limit < 0 {
+		return sel, fmt.Errorf("invalid limit %d", limit)
+	}
+	return sel, nil
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseTime accepts RFC 3339 timestamps or plain dates (UTC midnight)
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// parseStageWorkers parses stage=workers pairs such as alihunter=4,reviews=20
+func parseStageWorkers(s string) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, pair := range splitList(s) {
+		stage, value, ok := strings.Cut(pair, "=")
+		stage = strings.TrimSpace(stage)
+		if _, known := defaultStageWorkers[stage]; !ok || !known {
+			return nil, fmt.Errorf("invalid stage %q, expected one of normalize, alihunter, aliexpress, reviews, score", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid worker count %q for stage %s", value, stage)
+		}
+		out[stage] = n
+	}
+	return out, nil
+}
+
+// sidecarPath derives a file written next to a report, e.g.
+// runs/report.json -> runs/report.failed.ndjson
+func sidecarPath(path, suffix string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + suffix
+}
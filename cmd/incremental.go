@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// incremental reuses the reports of an earlier run: products that are still
+// fresh are kept as they are, stale or failed ones are fetched again and keep
+// their human labels
+type incremental struct {
+	maxAge   time.Duration // 0 keeps every earlier report
+	now      time.Time
+	fallback time.Time // fetch time of reports written before FetchedAt existed
+	reports  map[string]report.Report
+	order    []string        // keys in the order of the earlier report
+	seen     map[string]bool // keys produced by this run, touched by the writer only
+}
+
+// loadIncremental reads the earlier report a run builds on
+func loadIncremental(path string, maxAge time.Duration) (*incremental, error) {
+	run, err := report.LoadRun(path)
+	if err != nil {
+		return nil, err
+	}
+	inc := &incremental{
+		maxAge:   maxAge,
+		now:      time.Now(),
+		fallback: run.FinishedAt,
+		reports:  make(map[string]report.Report, len(run.Reports)),
+		seen:     make(map[string]bool),
+	}
+	if inc.fallback.IsZero() {
+		inc.fallback = run.StartedAt
+	}
+	for _, r := range run.Reports {
+		key := r.Key()
+		if _, dup := inc.reports[key]; !dup {
+			inc.order = append(inc.order, key)
+		}
+		inc.reports[key] = r
+	}
+	return inc, nil
+}
+
+// fresh reports whether an earlier report can be kept without fetching again
+func (inc *incremental) fresh(r report.Report) bool {
+	if r.Failed() {
+		return false
+	}
+	if inc.maxAge == 0 {
+		return true
+	}
+	fetchedAt := r.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = inc.fallback
+	}
+	return !fetchedAt.IsZero() && inc.now.Sub(fetchedAt) <= inc.maxAge
+}
+
+// reuse returns the earlier report of a product while it is fresh
+func (inc *incremental) reuse(prod model.SuggestionProduct) (report.Report, bool) {
+	r, ok := inc.reports[productKey(prod)]
+	if !ok || !inc.fresh(r) {
+		return report.Report{}, false
+	}
+	return r, true
+}
+
+// merge carries the labels of the earlier report over to a product fetched
+// again, and marks the product as part of this run. A stale report is kept
+// when fetching it again failed.
+func (inc *incremental) merge(res *comparisonResult) {
+	key := res.report.Key()
+	inc.seen[key] = true
+	old, ok := inc.reports[key]
+	if !ok || res.reused {
+		return
+	}
+	if res.report.Failed() && !old.Failed() {
+		slog.Warn("keeping earlier report, fetching it again failed", "shop_id", old.ShopID, "product_id", old.ProductID)
+		res.report, res.reused = old, true
+		return
+	}
+	res.report.CopyLabels(old)
+}
+
+// leftovers returns the earlier reports of products missing from this run's
+// input, so merging never drops labeled work
+func (inc *incremental) leftovers() []report.Report {
+	var out []report.Report
+	for _, key := range inc.order {
+		if !inc.seen[key] {
+			out = append(out, inc.reports[key])
+		}
+	}
+	return out
+}
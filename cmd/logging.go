@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// logOptions are the logging flags of the commands that call the providers
+type logOptions struct {
+	format *string
+	level  *string
+}
+
+func addLogFlags(fs *flag.FlagSet) logOptions {
+	return logOptions{
+		format: fs.String("log-format", "text", "log format: text or json (one object per line)"),
+		level:  fs.String("log-level", "info", "lowest level logged: debug, info, warn or error"),
+	}
+}
+
+// setup installs the default logger, writing to w. Lines of the standard log
+// package go through it as well.
+func (o logOptions) setup(w io.Writer) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*o.level)); err != nil {
+		return fmt.Errorf("invalid -log-level %q, expected debug, info, warn or error", *o.level)
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch *o.format {
+	case "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return fmt.Errorf("invalid -log-format %q, expected text or json", *o.format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// withRunID adds the run ID to every following log line
+func withRunID(runID string) {
+	slog.SetDefault(slog.Default().With("run_id", runID))
+}
+
+// productLogger returns a logger for the calls made for a product. attempt
+// counts the calls of a provider for the product, 1 for the first run.
+func productLogger(shopID, productID int64, attempt int) *slog.Logger {
+	return slog.Default().With("shop_id", shopID, "product_id", productID, "attempt", attempt)
+}
+
+// suggestionLogger is productLogger for an input product on its first attempt
+func suggestionLogger(prod model.SuggestionProduct) *slog.Logger {
+	return productLogger(prod.ShopID, prod.ProductID, 1)
+}
+
+// fatal logs an error and exits, like log.Fatal, for flag and setup errors
+// before anything needs closing
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// failed logs an error and returns exit status 1, for generate to return
+// once a run has resources its deferred calls must release
+func failed(msg string, args ...any) int {
+	slog.Error(msg, args...)
+	return 1
+}
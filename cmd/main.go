@@ -1,202 +1,485 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"flag"
-	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"os"
-	"sync"
+	"path/filepath"
+	"time"
 
-	"github.com/quanghia24/letsgo/internal/alihunter"
+	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/input"
+	"github.com/quanghia24/letsgo/internal/margin"
 	"github.com/quanghia24/letsgo/internal/model"
-	"github.com/quanghia24/letsgo/internal/rapidapi"
+	"github.com/quanghia24/letsgo/internal/pipeline"
+	"github.com/quanghia24/letsgo/internal/progress"
+	"github.com/quanghia24/letsgo/internal/ranking"
 	"github.com/quanghia24/letsgo/internal/report"
 )
 
 func main() {
+	// Sub-commands working on existing reports
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "accuracy":
+			runAccuracy(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "push-labels":
+			runPushLabels(os.Args[2:])
+			return
+		case "refresh":
+			runRefresh(os.Args[2:])
+			return
+		case "retry-failed":
+			runRetryFailed(os.Args[2:])
+			return
+		case "validate", "stats":
+			runValidate(os.Args[2:])
+			return
+		}
+	}
+	if code := generate(); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// generate compares the selected products and writes the report, or renders
+// it with -html. It returns the exit status, so deferred closes run first.
+func generate() int {
 	// Parse command-line flags
 	filePath := flag.String("local", "./docs/suggest_products.json", "path to local JSON file with RapidAPI product suggestions")
 	htmlFlag := flag.Bool("html", false, "generate HTML report")
+	inFlag := flag.String("in", "report.json", "with -html: report to render (.json or .ndjson)")
+	outFlag := flag.String("out", "", "file to write: the report (default report.json; a .ndjson or .jsonl path streams one product per line as it completes) or, with -html, the page (default report.html)")
+	templateFlag := flag.String("template", "", "with -html: custom report template instead of the embedded one")
+	filterFlag := flag.String("filters", "", "path to a JSON file with candidate filter rules (default: drop items without image or rating)")
+	pricesFlag := flag.String("prices", "", "CSV file of product_id,price with Shopify selling prices for margin estimation")
+	shippingFlag := flag.Float64("shipping", 0, "estimated shipping cost in USD added to each candidate's price for margins")
+	mongoFlag := flag.Bool("mongo", false, "read suggestion jobs from MongoDB (MONGO_URI) instead of -local")
+	shopFlag := flag.String("shop", "", "only these shops: comma separated shop IDs or myshopify domains")
+	jobFlag := flag.String("job", "", "only these comma separated job IDs")
+	statusFlag := flag.String("status", "", "only these comma separated statuses, e.g. SUCCESS")
+	sinceFlag := flag.String("since", "", "only jobs created at or after this time (YYYY-MM-DD or RFC 3339)")
+	untilFlag := flag.String("until", "", "only jobs created before this time (YYYY-MM-DD or RFC 3339)")
+	limitFlag := flag.Int("limit", 0, "process at most this many products (0 for all)")
+	sampleFlag := flag.Int("sample", 0, "draw this many products per stratum (0 to disable)")
+	sampleByFlag := flag.String("sample-by", input.SampleByShop, "sample stratum: shop, plan, app_plan or type")
+	seedFlag := flag.Uint64("seed", 1, "random seed of the sample")
+	sampleFromFlag := flag.String("sample-from", "", "re-draw the sample recorded in this report (or sample definition file)")
+	rankFlag := flag.String("rank", "", "re-rank each provider's results by weighted score, e.g. rating=1,volume=0.5,reviews=0.5,price=0.3,similarity=1")
+	runDirFlag := flag.String("run-dir", "", "checkpoint every completed product to this directory so the run can be resumed")
+	resumeFlag := flag.String("resume", "", "resume the run checkpointed in this directory: finished products are kept, failed and missing ones fetched")
+	incrementalFlag := flag.String("incremental", "", "build on this earlier report: only fetch products missing from it, failed or older than -max-age, keeping human labels")
+	maxAgeFlag := flag.Duration("max-age", 0, "with -incremental: fetch again products fetched longer ago than this, e.g. 168h (0 keeps all)")
+	workersFlag := flag.String("workers", "", "workers per pipeline stage, e.g. alihunter=4,aliexpress=4,reviews=20 (defaults: normalize=1, alihunter=7, aliexpress=7, reviews=14, score=2)")
+	progressFlag := flag.String("progress", progress.ModeAuto, "progress display on stderr: auto (live line on a terminal, a plain line every 10s otherwise), tty, plain or off")
+	deadLetterFlag := flag.String("dead-letter", "", "record failed provider calls in this NDJSON file (default: next to -out, e.g. report.failed.ndjson)")
+	metricsFlag := flag.String("metrics", "", "write provider latency, error and result metrics of the run to this file (default: metrics.json next to -out)")
+	metricsAddrFlag := flag.String("metrics-addr", "", "serve Prometheus metrics at /metrics on this address while the run lasts, e.g. :9090; there is no long-running serve mode")
+	graceFlag := flag.Duration("grace", 30*time.Second, "on SIGINT or SIGTERM, how long calls in flight may finish before they are cancelled and a partial report is written")
+	addCallTimeoutFlag(flag.CommandLine)
+	logOpts := addLogFlags(flag.CommandLine)
 	flag.Parse()
 
+	var resumed *report.Envelope
+	if *resumeFlag != "" {
+		meta, err := loadRunMeta(*resumeFlag)
+		if err != nil {
+			fatal("cannot resume", "error", err)
+		}
+		// replay the command line of the interrupted run
+		if err := flag.CommandLine.Parse(meta.Args); err != nil {
+			fatal("cannot resume", "error", err)
+		}
+		*runDirFlag = *resumeFlag
+		resumed = &meta
+	}
+
+	// log lines go through the progress display so they don't run into its line
+	tracker := progress.New(candidate.ProviderAliHunter, candidate.ProviderAliExpress)
+	if err := logOpts.setup(tracker); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+
+	if *outFlag == "" {
+		switch {
+		case *htmlFlag:
+			*outFlag = "report.html"
+		case *runDirFlag != "":
+			*outFlag = filepath.Join(*runDirFlag, "report.json")
+		default:
+			*outFlag = "report.json"
+		}
+	}
+
+	var prices map[int64]float64
+	if *pricesFlag != "" {
+		var err error
+		if prices, err = margin.LoadPriceFile(*pricesFlag); err != nil {
+			fatal("invalid -prices", "error", err)
+		}
+	}
+
 	// Generates an interactive HTML comparison report: only run on htmlFlag set to true
 	if *htmlFlag {
-		fmt.Println("🌶️ Generating HTML report")
-		var comparisons []report.Report
-		fileBytes, err := os.ReadFile("report.json")
+		slog.Info("generating HTML report", "in", *inFlag)
+		run, err := report.LoadRun(*inFlag)
 		if err != nil {
-			log.Fatalf("failed to read report.json: %v", err)
+			fatal("failed to load report", "error", err)
 		}
-		if err := json.Unmarshal(fileBytes, &comparisons); err != nil {
-			log.Fatalf("failed to unmarshal report.json: %v", err)
+		comparisons := run.Reports
+
+		// Recompute margins when selling prices are provided at render time
+		if prices != nil {
+			for i := range comparisons {
+				price, ok := prices[comparisons[i].ProductID]
+				if !ok {
+					price = comparisons[i].SellingPrice
+				}
+				margin.Apply(&comparisons[i], price, *shippingFlag)
+			}
 		}
 
-		if err := report.GenerateHTMLReport(comparisons, "report.html"); err != nil {
-			log.Fatalf("failed to generate report: %v", err)
+		if err := report.GenerateHTMLReport(run, *outFlag, *templateFlag); err != nil {
+			fatal("failed to generate report", "error", err)
 		}
 
-		fmt.Println("⭐ Report successfully generated and saved to report.html")
-		return
+		slog.Info("HTML report saved", "out", *outFlag)
+		return 0
 	}
 
-	// Gererate comparison report from local JSON file
-	fmt.Println("1️⃣ Reading: ", *filePath)
-	fileBytes, err := os.ReadFile(*filePath)
+	weights, err := ranking.ParseWeights(*rankFlag)
+	if err != nil {
+		fatal("invalid -rank", "error", err)
+	}
+	filters, err := configs.LoadFilterConfig(*filterFlag)
 	if err != nil {
-		log.Fatal("cannot read local json file:", err)
+		fatal("invalid -filters", "error", err)
 	}
-	var ShopGroupResponses []model.ShopGroup
-	if err := json.Unmarshal(fileBytes, &ShopGroupResponses); err != nil {
-		log.Fatal("cannot unmarshal json data:", err)
+	stageWorkers, err := parseStageWorkers(*workersFlag)
+	if err != nil {
+		fatal("invalid -workers", "error", err)
+	}
+	opts := compareOptions{weights: weights, filters: filters, prices: prices, shipping: *shippingFlag}
+	if !weights.IsZero() {
+		slog.Info("ranking candidates", "weights", weights.String())
 	}
-	fmt.Println("⭐ Done reading JSON file")
 
-	// 2. request product data from alihunter API and aliexpress then collect comparisons
-	fmt.Println("2️⃣ Fetching data:", *filePath)
-
-	type result struct {
-		index      int
-		comparison report.Report
-	}
-
-	// Calculate total products across all shops
-	total := 0
-	for _, shop := range ShopGroupResponses {
-		total += len(shop.SuggestionProducts)
-	}
-
-	results := make([]result, total)
-	resultsChan := make(chan result, total)
-	sem := make(chan struct{}, 7) // semaphore limit to 7 concurrent requests, not to overwhelm the APIs, why 7? cuz I like it =))
-	var wg sync.WaitGroup
-
-	// Use a global index counter
-	globalIdx := 0
-	for _, shop := range ShopGroupResponses {
-		for _, product := range shop.SuggestionProducts {
-			wg.Add(1)
-			currIndex := globalIdx // index for storing result in order
-			globalIdx++
-
-			go func(idx int, prod model.SuggestionProduct) {
-				defer wg.Done()
-				sem <- struct{}{}        // Acquire semaphore
-				defer func() { <-sem }() // Release semaphore
-
-				var aliHunterProducts []model.AliHunterProduct
-				var aliHunterOrigin []model.AliHunterProduct
-				var aliexpressProducts []model.AliExpressProduct
-				var aliexpressOrigin []model.AliExpressProduct
-				var innerWg sync.WaitGroup
-
-				innerWg.Add(2)
-
-				// Fetch AliHunter
-				go func() {
-					defer innerWg.Done()
-					products, originals, err := alihunter.AliHunterSearchByImage(prod.ImageURL)
-					if err != nil {
-						log.Printf("aliHunter failed for %d: %v\n", prod.ProductID, err)
-						aliHunterProducts = []model.AliHunterProduct{}
-						aliHunterOrigin = []model.AliHunterProduct{}
-					} else { // query total reviews for each product
-						for i := range products {
-							count, err := report.GetReviewsCount(products[i].ProductID)
-							if err != nil {
-								log.Printf("failed to get reviews count for aliHunter product %s: %v\n", products[i].ProductID, err)
-							}
-							products[i].TotalReview = count
-						}
-						for i := range originals {
-							count, err := report.GetReviewsCount(originals[i].ProductID)
-							if err != nil {
-								log.Printf("failed to get reviews count for aliHunter product %s: %v\n", originals[i].ProductID, err)
-							}
-							originals[i].TotalReview = count
-						}
-
-						aliHunterProducts = products
-						aliHunterOrigin = originals
-					}
-
-				}()
-
-				// Fetch AliExpress
-				go func() {
-					defer innerWg.Done()
-					products, originals, err := rapidapi.AliExpressSearchByImage(prod.ImageURL)
-					if err != nil {
-						log.Printf("aliExpress failed for %d: %v\n", prod.ProductID, err)
-						aliexpressProducts = []model.AliExpressProduct{}
-						aliexpressOrigin = []model.AliExpressProduct{}
-					} else {
-						for i := range products {
-							count, err := report.GetReviewsCount(products[i].ProductID)
-							if err != nil {
-								log.Printf("failed to get reviews count for aliExpress product %s: %v\n", products[i].ProductID, err)
-							}
-							products[i].TotalReview = count
-						}
-						for i := range originals {
-							count, err := report.GetReviewsCount(originals[i].ProductID)
-							if err != nil {
-								log.Printf("failed to get reviews count for AliExpress product %s: %v\n", originals[i].ProductID, err)
-							}
-							originals[i].TotalReview = count
-						}
-
-						aliexpressProducts = products
-						aliexpressOrigin = originals
-					}
-				}()
-
-				innerWg.Wait() // Wait for both API calls to complete
-
-				// Take top 3 local products
-				localProducts, localOrigin := report.TakeTopProducts(prod.Products)
-
-				// Send result to channel
-				resultsChan <- result{
-					index: idx,
-					comparison: report.Report{
-						ProductTitle:        prod.Product.Title,
-						ProductID:           prod.ProductID,
-						ImageURL:            prod.ImageURL,
-						ShopID:              prod.ShopID,
-						LocalRapidAPITop:    localProducts,
-						LocalRapidAPIOrigin: localOrigin,
-						AliHunterTop:        aliHunterProducts,
-						AliHunterOrigin:     aliHunterOrigin,
-						AliExpressTop:       aliexpressProducts,
-						AliExpressOrigin:    aliexpressOrigin,
-					},
+	selection, err := parseSelection(*shopFlag, *jobFlag, *statusFlag, *sinceFlag, *untilFlag, *limitFlag)
+	if err != nil {
+		fatal("invalid selection", "error", err)
+	}
+
+	sampling := *sampleFromFlag != "" || *sampleFlag > 0
+	var source func(emit func(comparisonJob)) error
+	total := 0 // products to process, unknown while streaming
+	inputInfo := report.InputInfo{Source: "file", Path: *filePath}
+	var sample *input.Sample
+
+	if !*mongoFlag && input.IsNDJSON(*filePath) && !sampling {
+		// Stream NDJSON input line by line so memory stays flat
+		slog.Info("streaming input", "path", *filePath)
+		source = func(emit func(comparisonJob)) error {
+			return streamSelection(*filePath, selection, emit)
+		}
+	} else {
+		var ShopGroupResponses []model.ShopGroup
+		switch {
+		case *mongoFlag:
+			cfg := configs.GetMongoConfig()
+			inputInfo = report.InputInfo{Source: "mongo", Path: cfg.Database + "." + cfg.SuggestionCollection}
+			slog.Info("querying MongoDB", "collection", inputInfo.Path)
+			ShopGroupResponses, err = loadFromMongo(cfg, mongoQuery(selection))
+			if err != nil {
+				fatal("cannot load suggestions from mongo", "error", err)
+			}
+		case input.IsCSV(*filePath):
+			if len(selection.Statuses) > 0 {
+				// every row would be dropped otherwise
+				slog.Warn("ignoring -status, CSV rows carry no job status", "status", *statusFlag)
+				selection.Statuses = nil
+			}
+			slog.Info("reading input", "path", *filePath)
+			ShopGroupResponses, err = input.LoadCSV(*filePath)
+			if err != nil {
+				fatal("cannot load local csv file", "error", err)
+			}
+		case input.IsNDJSON(*filePath):
+			// sampling needs every product up front
+			slog.Info("reading input", "path", *filePath)
+			ShopGroupResponses, err = input.LoadNDJSON(*filePath)
+			if err != nil {
+				fatal("cannot load local ndjson file", "error", err)
+			}
+		default:
+			// Gererate comparison report from local JSON file
+			slog.Info("reading input", "path", *filePath)
+			ShopGroupResponses, err = input.LoadJSON(*filePath)
+			if err != nil {
+				fatal("cannot load local json file", "error", err)
+			}
+		}
+
+		slog.Info("input loaded", "source", inputInfo.Source, "shops", len(ShopGroupResponses))
+
+		if !selection.IsZero() {
+			ShopGroupResponses = input.Select(ShopGroupResponses, selection)
+			slog.Info("selected products", "shops", len(ShopGroupResponses))
+		}
+
+		// Draw a reproducible sample, recorded in the report envelope
+		if sampling {
+			sample = &input.Sample{}
+			if *sampleFromFlag != "" {
+				if *sample, err = input.LoadSample(*sampleFromFlag); err != nil {
+					fatal("invalid -sample-from", "error", err)
+				}
+				ShopGroupResponses = input.Replay(ShopGroupResponses, *sample)
+			} else {
+				*sample = input.Sample{Size: *sampleFlag, By: *sampleByFlag, Seed: *seedFlag}
+				if ShopGroupResponses, err = input.Draw(ShopGroupResponses, sample); err != nil {
+					fatal("cannot draw sample", "error", err)
 				}
+			}
+			slog.Info("sampled products", "products", len(sample.Products), "size", sample.Size, "by", sample.By, "seed", sample.Seed)
+		}
 
-			}(currIndex, product)
+		for _, shop := range ShopGroupResponses {
+			total += len(shop.SuggestionProducts)
+		}
+		source = func(emit func(comparisonJob)) error {
+			emitGroups(ShopGroupResponses, emit)
+			return nil
 		}
 	}
 
-	// Close channel when all goroutines complete
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
+	env, err := newRunEnvelope(inputInfo, opts)
+	if err != nil {
+		fatal("cannot describe run", "error", err)
+	}
+	env.Sample = sample
+	if resumed != nil {
+		env.RunID, env.StartedAt, env.Args = resumed.RunID, resumed.StartedAt, resumed.Args
+	}
+	withRunID(env.RunID)
 
-	// Collect results
-	for res := range resultsChan {
-		results[res.index] = res
+	// Checkpoint completed products so the run can be resumed
+	var cp *checkpoint
+	if *runDirFlag != "" {
+		if cp, err = openCheckpoint(*runDirFlag, resumed != nil); err != nil {
+			fatal("cannot checkpoint run", "error", err)
+		}
+		defer cp.Close()
+		if err := cp.writeMeta(env); err != nil {
+			return failed("cannot checkpoint run", "error", err)
+		}
+		if resumed != nil {
+			slog.Info("resuming run", "run_dir", *runDirFlag, "done", len(cp.done))
+		} else {
+			slog.Info("checkpointing run", "run_dir", *runDirFlag)
+		}
+	}
+	// Build on an earlier report, fetching only what is missing or stale
+	var inc *incremental
+	if *incrementalFlag != "" {
+		if inc, err = loadIncremental(*incrementalFlag, *maxAgeFlag); err != nil {
+			return failed("invalid -incremental", "error", err)
+		}
+		slog.Info("reusing fresh products", "report", *incrementalFlag, "max_age", *maxAgeFlag)
 	}
 
-	// Build ordered comparisons list -> default behaviour: export to json
-	comparisons := make([]report.Report, total)
-	for i, res := range results {
-		comparisons[i] = res.comparison
+	opts.reuse = func(prod model.SuggestionProduct) (report.Report, bool) {
+		if cp != nil {
+			if r, ok := cp.reuse(prod); ok {
+				return r, true
+			}
+		}
+		if inc != nil {
+			return inc.reuse(prod)
+		}
+		return report.Report{}, false
+	}
+	// Failed provider calls go to a dead-letter file for retry-failed
+	if *deadLetterFlag == "" {
+		*deadLetterFlag = sidecarPath(*outFlag, ".failed.ndjson")
+	}
+	if err := os.Remove(*deadLetterFlag); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return failed("cannot reset dead-letter file", "error", err)
 	}
 
-	if err := report.GenerateJSONComparisonReport(comparisons); err != nil {
-		log.Fatalf("failed to generate JSON report: %v", err)
+	// record keeps a finished comparison, handing its report to out.
+	// Skipped products are listed as unprocessed; the counts only take a
+	// product once it is out.
+	record := func(res *comparisonResult, out func(report.Report) error) error {
+		if res.skipped {
+			// an earlier report of it is still carried over by inc.leftovers
+			env.Unprocessed = append(env.Unprocessed, report.RefOf(res.report))
+			return nil
+		}
+		if inc != nil {
+			inc.merge(res)
+		}
+		if !res.reused {
+			if err := report.AppendDeadLetters(*deadLetterFlag, report.DeadLetters(res.report, env.RunID)); err != nil {
+				return err
+			}
+		}
+		if cp != nil {
+			if err := cp.record(*res); err != nil {
+				return err
+			}
+		}
+		if err := out(res.report); err != nil {
+			if inc != nil {
+				// carried over by inc.leftovers like a skipped product
+				delete(inc.seen, res.report.Key())
+			}
+			return err
+		}
+		env.Counts.Add(res.report)
+		if res.reused {
+			env.Counts.Reused++
+		}
+		return nil
 	}
 
-	fmt.Println("⭐ Finished fetching from alihunter API and preparing comparisons")
+	// 2. request product data from alihunter API and aliexpress then collect comparisons
+	if *metricsFlag == "" {
+		*metricsFlag = filepath.Join(filepath.Dir(*outFlag), "metrics.json")
+	}
+	if *metricsAddrFlag != "" {
+		if err := serveMetrics(*metricsAddrFlag); err != nil {
+			return failed("invalid -metrics-addr", "error", err)
+		}
+	}
+
+	slog.Info("fetching products", "total", total)
+	opts.progress = tracker
+	opts.progress.SetTotal(total)
+	if err := opts.progress.Start(os.Stderr, *progressFlag); err != nil {
+		return failed("invalid -progress", "error", err)
+	}
+	defer opts.progress.Stop()
+
+	// Each product of an NDJSON report is written as soon as it completes,
+	// between an envelope header and trailer
+	var w *report.NDJSONWriter
+	if input.IsNDJSON(*outFlag) {
+		if w, err = report.CreateNDJSON(*outFlag); err != nil {
+			return failed("failed to create NDJSON report", "error", err)
+		}
+	}
+
+	// Ctrl-C stops the run but still writes the products done so far
+	sd := handleShutdown(*graceFlag)
+
+	var stages []pipeline.Metrics
+	var runErr error
+	code := 0 // of failures after the run
+	if w != nil {
+		if err := w.WriteEnvelope(env); err != nil {
+			runErr = err
+		} else {
+			stages, runErr = runComparisons(sd, source, opts, stageWorkers, func(res comparisonResult) error {
+				return record(&res, w.Write)
+			})
+		}
+		if inc != nil {
+			for _, r := range inc.leftovers() {
+				if err = w.Write(r); err != nil {
+					runErr = errors.Join(runErr, err)
+					break
+				}
+				env.Counts.Add(r)
+			}
+		}
+		// the trailer lists what was left unprocessed, also after a failed write
+		env.FinishedAt = time.Now().UTC()
+		env.Incomplete = runErr != nil || len(env.Unprocessed) > 0
+		err = w.WriteEnvelope(env)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+		if runErr != nil {
+			slog.Error("failed to generate NDJSON report", "error", runErr)
+		}
+	} else {
+		// Collect results in input order -> default behaviour: export to json
+		var comparisons []report.Report
+		var filled []bool // skipped products leave holes
+		stages, runErr = runComparisons(sd, source, opts, stageWorkers, func(res comparisonResult) error {
+			for len(comparisons) <= res.index {
+				comparisons = append(comparisons, report.Report{})
+				filled = append(filled, false)
+			}
+			return record(&res, func(r report.Report) error {
+				comparisons[res.index], filled[res.index] = r, true
+				return nil
+			})
+		})
+		if runErr != nil {
+			slog.Error("failed to compare products", "error", runErr)
+		}
+		kept := comparisons[:0]
+		for i, r := range comparisons {
+			if filled[i] {
+				kept = append(kept, r)
+			}
+		}
+		comparisons = kept
+		if inc != nil {
+			for _, r := range inc.leftovers() {
+				env.Counts.Add(r)
+				comparisons = append(comparisons, r)
+			}
+		}
+		env.FinishedAt = time.Now().UTC()
+		env.Incomplete = runErr != nil || len(env.Unprocessed) > 0
+		env.Reports = comparisons
+		if err := report.GenerateJSONComparisonReport(env, *outFlag); err != nil {
+			slog.Error("failed to generate JSON report", "error", err)
+			code = 1
+		}
+	}
+
+	opts.progress.Stop()
+	sd.release()
+
+	if cp != nil {
+		if err := cp.writeMeta(env); err != nil {
+			slog.Error("cannot checkpoint run", "error", err)
+			code = 1
+		}
+	}
+
+	for _, m := range stages {
+		slog.Info("pipeline stage", "stage", m.Name, "workers", m.Workers, "items", m.Items,
+			"busy", m.Busy, "idle", m.Idle, "blocked", m.Blocked)
+	}
+	if err := writeMetrics(*metricsFlag, env, stages); err != nil {
+		slog.Error("cannot write metrics", "error", err)
+		code = 1
+	}
+	slog.Info("run finished", "metrics", *metricsFlag, "out", *outFlag, "products", env.Counts.Products, "reused", env.Counts.Reused)
+	if failed := env.Counts.Errors[candidate.ProviderAliHunter] + env.Counts.Errors[candidate.ProviderAliExpress]; failed > 0 {
+		slog.Warn("provider calls failed", "calls", failed, "dead_letter", *deadLetterFlag, "retry", "retry-failed -in "+*outFlag)
+	}
+	if runErr != nil {
+		slog.Warn("run stopped, the report is incomplete", "unprocessed", len(env.Unprocessed))
+		return 1
+	}
+	if sig, ok := sd.interrupted(); ok {
+		slog.Warn("run interrupted, the report is incomplete", "signal", sig.String(), "unprocessed", len(env.Unprocessed))
+		return exitCode(sig)
+	}
+	return code
 }
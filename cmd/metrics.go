@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/httpclient"
+	"github.com/quanghia24/letsgo/internal/pipeline"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// runMetrics is the metrics.json summary of a run
+type runMetrics struct {
+	RunID      string                        `json:"run_id"`
+	StartedAt  time.Time                     `json:"started_at"`
+	FinishedAt time.Time                     `json:"finished_at"`
+	Providers  map[string]httpclient.Summary `json:"providers"`
+	Stages     []pipeline.Metrics            `json:"stages,omitempty"`
+}
+
+// writeMetrics writes the provider calls observed during the run and the
+// pipeline stage metrics to path
+func writeMetrics(path string, env report.Envelope, stages []pipeline.Metrics) error {
+	data, err := json.MarshalIndent(runMetrics{
+		RunID:      env.RunID,
+		StartedAt:  env.StartedAt,
+		FinishedAt: env.FinishedAt,
+		Providers:  httpclient.Snapshot(),
+		Stages:     stages,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// serveMetrics exposes the provider metrics for Prometheus at /metrics on
+// addr for as long as the process runs. There is no long-running serve mode,
+// so the endpoint only lives as long as a run.
+func serveMetrics(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", httpclient.Handler())
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			slog.Error("metrics endpoint stopped", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "url", "http://"+ln.Addr().String()+"/metrics")
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/input"
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/mongodb"
+)
+
+const mongoTimeout = 2 * time.Minute
+
+// mongoQuery pushes the parts of a selection that MongoDB can evaluate on the
+// suggestion collection; shop domains and the limit are applied afterwards
+func mongoQuery(sel input.Selection) mongodb.Query {
+	q := mongodb.Query{
+		JobIDs:      sel.JobIDs,
+		Statuses:    sel.Statuses,
+		CreatedFrom: sel.CreatedFrom,
+		CreatedTo:   sel.CreatedTo,
+	}
+	// domains live on the shop, filtering by ID alone would drop their matches
+	if len(sel.Domains) == 0 {
+		q.ShopIDs = sel.ShopIDs
+	}
+	return q
+}
+
+// loadFromMongo reads and groups the suggestion jobs matching the query
+func loadFromMongo(cfg *configs.MongoConfig, q mongodb.Query) ([]model.ShopGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	client, err := mongodb.Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(context.Background())
+
+	return mongodb.LoadShopGroups(ctx, client, cfg, q)
+}
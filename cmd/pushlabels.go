@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/mongodb"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// runPushLabels writes the reviewed labels of the local column back to the
+// suggestion documents in MongoDB
+func runPushLabels(args []string) {
+	fs := flag.NewFlagSet("push-labels", flag.ExitOnError)
+	inPath := fs.String("in", "report.json", "path to a labeled report exported from the HTML page")
+	dryRun := fs.Bool("dry-run", false, "preview the changes without writing to MongoDB")
+	auditPath := fs.String("audit", "labels-audit.ndjson", "append applied changes to this NDJSON audit log")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each MongoDB read or update")
+	logOpts := addLogFlags(fs)
+	fs.Parse(args)
+	if err := logOpts.setup(os.Stderr); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+
+	comparisons, err := report.LoadJSONReport(*inPath)
+	if err != nil {
+		fatal("failed to load report", "error", err)
+	}
+
+	var updates []mongodb.LabelUpdate
+	missingIDs, unreviewed := 0, 0
+	for _, r := range comparisons {
+		id, err := primitive.ObjectIDFromHex(r.SuggestionID)
+		if err != nil {
+			missingIDs++
+			continue
+		}
+		// Only the local column comes from the suggestion's products array.
+		// Candidates never shown for review still carry the production flags,
+		// or false from the export, and are left alone.
+		for _, c := range r.LabeledCandidates(candidate.ProviderLocal) {
+			if !c.Reviewed {
+				unreviewed++
+				continue
+			}
+			updates = append(updates, mongodb.LabelUpdate{
+				SuggestionID: id,
+				ProductID:    c.ProductID,
+				Matching:     c.Matching,
+				Similar:      c.Similar,
+			})
+		}
+	}
+	if missingIDs > 0 {
+		slog.Warn("skipped products without a suggestion _id, the report was generated before push-labels support", "products", missingIDs)
+	}
+	if unreviewed > 0 {
+		slog.Info("skipped candidates without reviewed labels, export the report from the HTML page to label them", "candidates", unreviewed)
+	}
+
+	cfg := configs.GetMongoConfig()
+	connectCtx, cancel := context.WithTimeout(context.Background(), *timeout)
+	client, err := mongodb.Connect(connectCtx, cfg)
+	cancel()
+	if err != nil {
+		fatal("failed to connect to mongo", "error", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	// Ctrl-C stops before the next suggestion, the rest is reported as left
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	changes, err := mongodb.PushLabels(ctx, client, cfg, updates, *dryRun, *timeout)
+	written, left := 0, 0
+	for _, c := range changes {
+		switch {
+		case c.Error != "":
+			left++
+			slog.Error("label not written", "suggestion_id", c.SuggestionID, "productid", c.ProductID, "error", c.Error)
+		case c.Skipped != "":
+			left++
+			slog.Warn("label skipped", "suggestion_id", c.SuggestionID, "productid", c.ProductID, "reason", c.Skipped)
+		default:
+			written++
+			slog.Info("label change", "suggestion_id", c.SuggestionID, "productid", c.ProductID, "dry_run", c.DryRun,
+				"matching_old", c.Old.Matching, "matching", c.New.Matching, "similar_old", c.Old.Similar, "similar", c.New.Similar)
+		}
+	}
+	// record what was applied even when a later update failed
+	if !*dryRun && len(changes) > 0 {
+		if auditErr := mongodb.AppendAuditLog(*auditPath, changes); auditErr != nil {
+			slog.Error("failed to write audit log", "audit", *auditPath, "error", auditErr)
+		}
+	}
+	if err != nil {
+		fatal("failed to push labels", "written", written, "left", left, "error", err)
+	}
+
+	if *dryRun {
+		slog.Info("dry run, nothing written", "changes", written, "left", left)
+		return
+	}
+	slog.Info("pushed label changes", "written", written, "left", left, "audit", *auditPath)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// runRefresh fetches one provider again for every product of an existing
+// report. Only that provider's columns are replaced, which also clears their
+// labels; the other columns and their labels stay as they are, and so do the
+// columns of products whose call fails.
+func runRefresh(args []string) {
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	provider := fs.String("provider", "", "provider to fetch again: alihunter or aliexpress")
+	inPath := fs.String("in", "report.json", "report to refresh (.json or .ndjson)")
+	outPath := fs.String("out", "", "where to write the refreshed report (default: overwrite -in)")
+	deadLetterPath := fs.String("dead-letter", "", "append calls that fail to this file (default: next to -out, e.g. report.failed.ndjson)")
+	addCallTimeoutFlag(fs)
+	logOpts := addLogFlags(fs)
+	fs.Parse(args)
+	if err := logOpts.setup(os.Stderr); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+
+	if *provider != candidate.ProviderAliHunter && *provider != candidate.ProviderAliExpress {
+		fmt.Fprintf(fs.Output(), "-provider must be %s or %s\n", candidate.ProviderAliHunter, candidate.ProviderAliExpress)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *outPath == "" {
+		*outPath = *inPath
+	}
+	if *deadLetterPath == "" {
+		*deadLetterPath = sidecarPath(*outPath, ".failed.ndjson")
+	}
+
+	env, err := report.LoadRun(*inPath)
+	if err != nil {
+		fatal("failed to load report", "error", err)
+	}
+	withRunID(env.RunID)
+	opts, err := optionsFromEnvelope(env)
+	if err != nil {
+		fatal("failed to restore run settings", "error", err)
+	}
+
+	targets := make(map[string][]providerTarget, len(env.Reports))
+	for _, r := range env.Reports {
+		targets[r.Key()] = []providerTarget{{provider: *provider, attempt: 1}}
+	}
+	slog.Info("refreshing provider", "provider", *provider, "products", len(targets))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	refreshed, _ := patchProviders(ctx, &env, targets, opts)
+	if ctx.Err() != nil {
+		slog.Warn("refresh stopped by a signal, the other products keep their earlier results", "refreshed", len(refreshed), "products", len(targets))
+	}
+
+	failed, err := rewriteDeadLetters(*deadLetterPath, env, *provider, refreshed)
+	if err != nil {
+		fatal("failed to write dead letters", "error", err)
+	}
+
+	if err := report.WriteRun(env, *outPath); err != nil {
+		fatal("failed to write report", "error", err)
+	}
+	slog.Info("refreshed report", "provider", *provider, "out", *outPath)
+	if failed > 0 {
+		slog.Warn("provider calls failed", "calls", failed, "dead_letter", *deadLetterPath, "retry", "retry-failed -in "+*outPath)
+	}
+}
+
+// rewriteDeadLetters rewrites the dead-letter file with the failed calls of
+// env, so running refresh again never lists a call twice. The refreshed
+// products get fresh entries for provider; the other entries are kept while
+// the report still shows their call failed. It returns how many calls of the
+// refresh failed.
+func rewriteDeadLetters(path string, env report.Envelope, provider string, refreshed []report.Report) (int, error) {
+	earlier, err := report.LoadDeadLetters(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	type call struct{ key, provider string }
+	current := make(map[call]report.DeadLetter)
+	var order []call
+	for _, r := range env.Reports {
+		for _, d := range report.DeadLetters(r, env.RunID) {
+			c := call{d.Key(), d.Provider}
+			if _, ok := current[c]; !ok {
+				order = append(order, c)
+			}
+			current[c] = d
+		}
+	}
+	done := make(map[string]bool, len(refreshed))
+	failed := 0
+	for _, r := range refreshed {
+		done[r.Key()] = true
+		if _, ok := current[call{r.Key(), provider}]; ok {
+			failed++
+		}
+	}
+	// earlier entries keep their attempt count, unless refreshed
+	for _, d := range earlier {
+		c := call{d.Key(), d.Provider}
+		if _, ok := current[c]; !ok {
+			continue
+		}
+		if done[c.key] && c.provider == provider {
+			continue
+		}
+		current[c] = d
+	}
+
+	entries := make([]report.DeadLetter, 0, len(order))
+	for _, c := range order {
+		entries = append(entries, current[c])
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("failed to reset dead-letter file %s: %w", path, err)
+	}
+	return failed, report.AppendDeadLetters(path, entries)
+}
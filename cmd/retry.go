@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// providerTarget is a provider to fetch again for a product
+type providerTarget struct {
+	provider string
+	attempt  int // calls of the provider for the product, this one included
+}
+
+// runRetryFailed fetches again the provider/product pairs listed in a
+// dead-letter file and patches the results into the existing report. Other
+// providers, other products and all labels stay as they are.
+func runRetryFailed(args []string) {
+	fs := flag.NewFlagSet("retry-failed", flag.ExitOnError)
+	inPath := fs.String("in", "report.json", "report to patch (.json or .ndjson)")
+	deadLetterPath := fs.String("dead-letter", "", "dead-letter file of the run (default: next to -in, e.g. report.failed.ndjson)")
+	outPath := fs.String("out", "", "where to write the patched report (default: overwrite -in)")
+	addCallTimeoutFlag(fs)
+	logOpts := addLogFlags(fs)
+	fs.Parse(args)
+	if err := logOpts.setup(os.Stderr); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+	if *deadLetterPath == "" {
+		*deadLetterPath = sidecarPath(*inPath, ".failed.ndjson")
+	}
+	if *outPath == "" {
+		*outPath = *inPath
+	}
+
+	env, err := report.LoadRun(*inPath)
+	if err != nil {
+		fatal("failed to load report", "error", err)
+	}
+	withRunID(env.RunID)
+	entries, err := report.LoadDeadLetters(*deadLetterPath)
+	if err != nil {
+		fatal("failed to load dead letters", "error", err)
+	}
+	opts, err := optionsFromEnvelope(env)
+	if err != nil {
+		fatal("failed to restore run settings", "error", err)
+	}
+
+	// providers to fetch again, by product
+	targets := make(map[string][]providerTarget)
+	for _, d := range entries {
+		targets[d.Key()] = addTarget(targets[d.Key()], providerTarget{provider: d.Provider, attempt: max(d.Attempt, 1) + 1})
+	}
+	slog.Info("retrying failed calls", "calls", len(entries), "products", len(targets), "dead_letter", *deadLetterPath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	retried, missing := patchProviders(ctx, &env, targets, opts)
+	dropped := make(map[string]bool)
+	for _, key := range missing {
+		slog.Warn("product of the dead-letter file is not in the report, skipped", "key", key, "in", *inPath)
+		dropped[key] = true
+	}
+
+	// whatever still fails stays in the dead-letter file for the next retry
+	var remaining []report.DeadLetter
+	for _, r := range retried {
+		dropped[r.Key()] = true
+		for _, d := range report.DeadLetters(r, env.RunID) {
+			d.Attempt = attemptOf(targets[d.Key()], d.Provider)
+			remaining = append(remaining, d)
+		}
+	}
+	// so do the calls of products a signal stopped the retry before
+	for _, d := range entries {
+		if !dropped[d.Key()] {
+			remaining = append(remaining, d)
+		}
+	}
+	if ctx.Err() != nil {
+		slog.Warn("retry stopped by a signal, products not started keep their dead-letter entries")
+	}
+	if err := os.Remove(*deadLetterPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fatal("failed to reset dead-letter file", "error", err)
+	}
+	if err := report.AppendDeadLetters(*deadLetterPath, remaining); err != nil {
+		fatal("failed to write dead letters", "error", err)
+	}
+
+	if err := report.WriteRun(env, *outPath); err != nil {
+		fatal("failed to write report", "error", err)
+	}
+	slog.Info("patched report", "out", *outPath, "products", len(retried), "still_failing", len(remaining))
+}
+
+// patchProviders fetches the given providers again for the reports of env
+// whose key is in targets, a few products at a time, and recounts the run.
+// It returns the patched reports and the keys not found in env.
+//
+// Once ctx is cancelled no further product is started and left out of the
+// patched reports; reports whose calls were cut short are left as they were.
+func patchProviders(ctx context.Context, env *report.Envelope, targets map[string][]providerTarget, opts compareOptions) (patched []report.Report, missing []string) {
+	found := make(map[string]bool)
+	started := make(map[string]bool)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+
+	for i := range env.Reports {
+		r := &env.Reports[i]
+		providers, ok := targets[r.Key()]
+		if !ok {
+			continue
+		}
+		found[r.Key()] = true
+		if ctx.Err() != nil {
+			continue
+		}
+		started[r.Key()] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, t := range providers {
+				if err := refetchProvider(ctx, r, t.provider, t.attempt, opts); err != nil && ctx.Err() == nil {
+					slog.Error("cannot fetch provider again", "shop_id", r.ShopID, "product_id", r.ProductID, "provider", t.provider, "error", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	env.Counts = report.Counts{}
+	for _, r := range env.Reports {
+		env.Counts.Add(r)
+		if started[r.Key()] {
+			patched = append(patched, r)
+		}
+	}
+	for key := range targets {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	return patched, missing
+}
+
+// addTarget adds t to a product's targets, once per provider with the
+// highest attempt
+func addTarget(list []providerTarget, t providerTarget) []providerTarget {
+	for i := range list {
+		if list[i].provider == t.provider {
+			list[i].attempt = max(list[i].attempt, t.attempt)
+			return list
+		}
+	}
+	return append(list, t)
+}
+
+// attemptOf returns the attempt of provider among a product's targets, 1
+// when it was not targeted
+func attemptOf(list []providerTarget, provider string) int {
+	for _, t := range list {
+		if t.provider == provider {
+			return t.attempt
+		}
+	}
+	return 1
+}
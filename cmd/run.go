@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/quanghia24/letsgo/internal/input"
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/pipeline"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// workers limits concurrent comparisons, not to overwhelm the APIs, why 7? cuz I like it =))
+const workers = 7
+
+// comparisonJob is one product to compare, numbered in input order
+type comparisonJob struct {
+	index   int
+	product model.SuggestionProduct
+}
+
+// errLimitReached stops a stream once the selection limit is reached
+var errLimitReached = errors.New("limit reached")
+
+// comparisonResult is a finished comparison. reused is set when the report
+// was kept from an earlier run instead of being fetched again. skipped is
+// set for a product the run was stopped before comparing; its report only
+// identifies the product.
+type comparisonResult struct {
+	index   int
+	report  report.Report
+	reused  bool
+	skipped bool
+}
+
+// Pipeline stages, in order; the two provider stages run side by side. Each
+// has its own pool of workers; see defaultStageWorkers.
+const (
+	stageLoad       = "load"
+	stageNormalize  = "normalize"
+	stageAliHunter  = "alihunter"
+	stageAliExpress = "aliexpress"
+	stageReviews    = "reviews"
+	stageScore      = "score"
+	stageWrite      = "write"
+)
+
+// defaultStageWorkers is the concurrency of the stages that have a pool. The
+// provider stages keep to workers calls at a time each; reviews are looked up
+// one at a time per product, so that stage gets more.
+var defaultStageWorkers = map[string]int{
+	stageNormalize:  1,
+	stageAliHunter:  workers,
+	stageAliExpress: workers,
+	stageReviews:    2 * workers,
+	stageScore:      2,
+}
+
+// teeBuffer is how many products one provider stage may search ahead of the
+// other before it waits for it
+const teeBuffer = 2 * workers
+
+// productWork is a product travelling through the comparison pipeline. Once
+// done is set, by reuse or a shutdown, the remaining stages pass it on
+// untouched.
+type productWork struct {
+	comparisonResult
+	done       bool
+	log        *slog.Logger
+	product    model.SuggestionProduct
+	aliHunter  columnWork[model.AliHunterProduct]
+	aliExpress columnWork[model.AliExpressProduct]
+}
+
+// runComparisons runs the jobs emitted by source through the staged pipeline
+// load → normalize → search both providers side by side → enrich reviews →
+// score → write and hands every finished comparison to write, in completion
+// order. write is never called concurrently. stageWorkers overrides the
+// concurrency of defaultStageWorkers.
+//
+// Once sd stops dispatching, the products source still emits are only kept
+// when they can be reused, the others are handed to write as skipped. Once
+// sd cancels calls, products still in flight are skipped as well. When write
+// fails the run stops the same way: the product it failed on and every one
+// after it are handed to write as skipped, and the error is returned.
+func runComparisons(sd *shutdown, source func(emit func(comparisonJob)) error, opts compareOptions, stageWorkers map[string]int, write func(res comparisonResult) error) ([]pipeline.Metrics, error) {
+	concurrency := func(stage string) int {
+		if n, ok := stageWorkers[stage]; ok {
+			return n
+		}
+		return defaultStageWorkers[stage]
+	}
+	p := pipeline.New(sd.calls)
+	ctx := p.Context()
+
+	// step runs fn on products still to compare. Products reaching it after
+	// calls are cancelled are skipped, since what earlier stages fetched may
+	// have been cut short.
+	step := func(fn func(w *productWork)) func(*productWork) *productWork {
+		return func(w *productWork) *productWork {
+			if w.done {
+				return w
+			}
+			if ctx.Err() != nil {
+				w.done, w.skipped = true, true
+				return w
+			}
+			fn(w)
+			return w
+		}
+	}
+
+	// search is step for the provider stages, which run side by side on the
+	// same products: fn only sets its own provider's column, and skipping is
+	// left to the stage after the join
+	search := func(fn func(w *productWork)) func(*productWork) *productWork {
+		return func(w *productWork) *productWork {
+			if !w.done && ctx.Err() == nil {
+				fn(w)
+			}
+			return w
+		}
+	}
+
+	loaded := pipeline.Source(p, stageLoad, func(emit func(*productWork)) error {
+		return source(func(job comparisonJob) {
+			w := &productWork{comparisonResult: comparisonResult{index: job.index}, product: job.product, log: suggestionLogger(job.product)}
+			if opts.reuse != nil {
+				if r, ok := opts.reuse(job.product); ok {
+					w.report, w.reused, w.done = r, true, true
+				}
+			}
+			if !w.done && (sd.dispatch.Err() != nil || ctx.Err() != nil) {
+				w.done, w.skipped = true, true
+			}
+			emit(w)
+		})
+	})
+
+	normalized := pipeline.Stage(p, stageNormalize, concurrency(stageNormalize), loaded, step(func(w *productWork) {
+		w.product.ImageURL = normalizeImageURL(w.product.ImageURL)
+	}))
+
+	toAliHunter, toAliExpress := pipeline.Tee(normalized, teeBuffer)
+	searchedAliHunter := pipeline.Stage(p, stageAliHunter, concurrency(stageAliHunter), toAliHunter, search(func(w *productWork) {
+		end := opts.progress.Call(aliHunterColumn.provider)
+		w.aliHunter = searchColumn(ctx, aliHunterColumn, w.product, w.log)
+		end(w.aliHunter.err)
+	}))
+	searchedAliExpress := pipeline.Stage(p, stageAliExpress, concurrency(stageAliExpress), toAliExpress, search(func(w *productWork) {
+		end := opts.progress.Call(aliExpressColumn.provider)
+		w.aliExpress = searchColumn(ctx, aliExpressColumn, w.product, w.log)
+		end(w.aliExpress.err)
+	}))
+	searched := pipeline.Join(searchedAliHunter, searchedAliExpress)
+
+	enriched := pipeline.Stage(p, stageReviews, concurrency(stageReviews), searched, step(func(w *productWork) {
+		enrichColumn(ctx, aliHunterColumn, w.aliHunter, opts, w.log)
+		enrichColumn(ctx, aliExpressColumn, w.aliExpress, opts, w.log)
+	}))
+
+	scored := pipeline.Stage(p, stageScore, concurrency(stageScore), enriched, step(func(w *productWork) {
+		w.report = scoreProduct(w.product, w.aliHunter, w.aliExpress, opts)
+	}))
+
+	// skip hands w to write as not compared, with a report that only
+	// identifies the product
+	skip := func(w *productWork) error {
+		w.skipped = true
+		w.report = report.Report{
+			SuggestionID: suggestionID(w.product),
+			ProductTitle: w.product.Product.Title,
+			ProductID:    w.product.ProductID,
+			ImageURL:     w.product.ImageURL,
+			ShopID:       w.product.ShopID,
+		}
+		return write(w.comparisonResult)
+	}
+
+	err := pipeline.Drain(p, stageWrite, scored, func(w *productWork) error {
+		if w.skipped {
+			return skip(w)
+		}
+		if err := write(w.comparisonResult); err != nil {
+			skip(w)
+			return err
+		}
+		opts.progress.Done(w.reused)
+		return nil
+	}, func(w *productWork) {
+		skip(w)
+	})
+	return p.Metrics(), err
+}
+
+// emitGroups emits every product of already loaded shop groups
+func emitGroups(groups []model.ShopGroup, emit func(comparisonJob)) {
+	index := 0
+	for _, shop := range groups {
+		for _, product := range shop.SuggestionProducts {
+			emit(comparisonJob{index: index, product: product})
+			index++
+		}
+	}
+}
+
+// streamSelection emits the selected products of an NDJSON input while it is
+// being read
+func streamSelection(path string, sel input.Selection, emit func(comparisonJob)) error {
+	index := 0
+	err := input.StreamNDJSON(path, func(shop model.Shop, p model.SuggestionProduct) error {
+		if !sel.MatchShop(shop) || !sel.MatchProduct(p) {
+			return nil
+		}
+		if sel.Limit > 0 && index >= sel.Limit {
+			return errLimitReached
+		}
+		emit(comparisonJob{index: index, product: p})
+		index++
+		return nil
+	})
+	if errors.Is(err, errLimitReached) {
+		return nil
+	}
+	return err
+}
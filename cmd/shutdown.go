@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// shutdown stops a run on SIGINT or SIGTERM: no new product is started, and
+// the calls in flight get a grace period before they are cancelled. A second
+// signal cancels them right away, a third one kills the process.
+type shutdown struct {
+	dispatch context.Context // done once no new product should start
+	calls    context.Context // done once calls in flight are cancelled
+
+	received atomic.Value // os.Signal
+	release  func()
+}
+
+// handleShutdown starts listening for signals until release is called, which
+// also cancels both contexts
+func handleShutdown(grace time.Duration) *shutdown {
+	dispatch, stopDispatch := context.WithCancel(context.Background())
+	calls, cancelCalls := context.WithCancel(context.Background())
+	s := &shutdown{dispatch: dispatch, calls: calls}
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	s.release = func() {
+		signal.Stop(sigs)
+		close(done)
+		stopDispatch()
+		cancelCalls()
+	}
+
+	go func() {
+		defer cancelCalls()
+		select {
+		case sig := <-sigs:
+			s.received.Store(sig)
+			slog.Warn("stopping run, no new products are started", "signal", sig.String(), "grace", grace)
+			stopDispatch()
+		case <-done:
+			return
+		}
+
+		select {
+		case <-time.After(grace):
+			slog.Warn("grace period over, cancelling calls in flight")
+		case sig := <-sigs:
+			slog.Warn("cancelling calls in flight", "signal", sig.String())
+		case <-done:
+			return
+		}
+		signal.Stop(sigs)
+	}()
+	return s
+}
+
+// interrupted returns the signal that stopped the run, if any
+func (s *shutdown) interrupted() (os.Signal, bool) {
+	sig, ok := s.received.Load().(os.Signal)
+	return sig, ok
+}
+
+// exitCode is the conventional status of a process stopped by sig
+func exitCode(sig os.Signal) int {
+	if n, ok := sig.(syscall.Signal); ok {
+		return 128 + int(n)
+	}
+	return 1
+}
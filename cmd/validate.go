@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/quanghia24/letsgo/internal/stats"
+)
+
+// runValidate prints statistics of an input file and the records that would
+// show up as broken cells in the report. Exits with status 1 when records
+// fail to parse.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "print the statistics as JSON")
+	logOpts := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: validate [-json] <input .json, .ndjson or .csv>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := logOpts.setup(os.Stderr); err != nil {
+		fatal("invalid logging flags", "error", err)
+	}
+
+	s, err := stats.Collect(fs.Arg(0))
+	if err != nil {
+		fatal("failed to read input", "error", err)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(s)
+	} else {
+		err = stats.WriteText(os.Stdout, s)
+	}
+	if err != nil {
+		fatal("failed to write statistics", "error", err)
+	}
+
+	if !s.OK() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,37 @@
+package configs
+
+import (
+	"os"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// EnvFile is the optional env file read before the environment, relative to
+// the working directory unless absolute
+var EnvFile = GetEnv("ENV_FILE", ".env.example")
+
+var loadEnvOnce sync.Once
+
+// loadEnv reads EnvFile once. A missing file is fine: variables set in the
+// environment and the built-in defaults still apply.
+func loadEnv() {
+	loadEnvOnce.Do(func() {
+		_ = godotenv.Load(EnvFile)
+	})
+}
+
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Redact hides a secret in recorded configuration, keeping whether it was set
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[redacted]"
+}
@@ -0,0 +1,27 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/quanghia24/letsgo/internal/filter"
+)
+
+// LoadFilterConfig reads candidate filter rules from a JSON file. Without a
+// path the built-in rules are used.
+func LoadFilterConfig(path string) (filter.Config, error) {
+	if path == "" {
+		return filter.DefaultConfig, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return filter.Config{}, fmt.Errorf("failed to read filter config %s: %w", path, err)
+	}
+	var cfg filter.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return filter.Config{}, fmt.Errorf("failed to parse filter config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,20 @@
+package configs
+
+type MongoConfig struct {
+	URI                  string
+	Database             string
+	SuggestionCollection string
+	ShopCollection       string
+}
+
+func GetMongoConfig() *MongoConfig {
+	// MongoDB defaults to a local mongod
+	loadEnv()
+
+	return &MongoConfig{
+		URI:                  GetEnv("MONGO_URI", "mongodb://localhost:27017"),
+		Database:             GetEnv("MONGO_DATABASE", "product_suggestion"),
+		SuggestionCollection: GetEnv("MONGO_SUGGESTION_COLLECTION", "suggestion_products"),
+		ShopCollection:       GetEnv("MONGO_SHOP_COLLECTION", "shops"),
+	}
+}
@@ -0,0 +1,304 @@
+package accuracy
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/report"
+	"github.com/quanghia24/letsgo/internal/templates"
+)
+
+// Label targets a suggestion can be evaluated against
+const (
+	TargetMatch   = "match"   // human ticked Match
+	TargetSimilar = "similar" // human ticked Match or Similar
+)
+
+// Options controls how machine suggestions are derived and evaluated.
+// A candidate carrying a similarity score, which only AliHunter reports, is
+// suggested when the score reaches Threshold. Production, when set, is the
+// report as generated, before labeling: the Matching/Similar flags of its
+// local column are the production engine's suggestions.
+type Options struct {
+	Target     string
+	Threshold  float64
+	Thresholds []float64 // sweep used for the precision/recall table
+	Worst      int       // number of disagreements to keep
+	Production []report.Report
+}
+
+// DefaultThresholds is the sweep used when Options.Thresholds is empty
+var DefaultThresholds = []float64{0.5, 0.55, 0.6, 0.65, 0.7, 0.75, 0.8, 0.85, 0.9, 0.95}
+
+// Confusion is a binary confusion matrix
+type Confusion struct {
+	TruePositive  int
+	FalsePositive int
+	FalseNegative int
+	TrueNegative  int
+}
+
+func (c Confusion) Precision() float64 {
+	return ratio(c.TruePositive, c.TruePositive+c.FalsePositive)
+}
+
+func (c Confusion) Recall() float64 {
+	return ratio(c.TruePositive, c.TruePositive+c.FalseNegative)
+}
+
+func (c Confusion) F1() float64 {
+	p, r := c.Precision(), c.Recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+// ThresholdStat holds the confusion matrix for one threshold of the sweep
+type ThresholdStat struct {
+	Threshold float64
+	Confusion Confusion
+}
+
+// Disagreement is a candidate where the suggestion and the human label differ
+type Disagreement struct {
+	ProductID  int64
+	ShopID     int64
+	QueryImage string
+	Kind       string // "false positive" or "false negative"
+	Candidate  candidate.Candidate
+	Distance   float64 // how far the score is from the threshold
+}
+
+// ProductionStat evaluates the production engine's flags of one provider
+type ProductionStat struct {
+	Provider   string
+	Candidates int
+	Confusion  Confusion
+}
+
+// Result is the outcome of an evaluation. Candidates without a similarity
+// score or a production flag are left out rather than counted as not
+// suggested.
+type Result struct {
+	Target        string
+	Threshold     float64
+	Products      int
+	Scored        int            // candidates carrying a similarity score
+	LeftOut       map[string]int // candidates by provider that could not be evaluated
+	Matrix        Confusion
+	Sweep         []ThresholdStat
+	Disagreements []Disagreement
+	Production    *ProductionStat // nil without Options.Production
+}
+
+type sample struct {
+	report    *report.Report
+	candidate candidate.Candidate
+	positive  bool
+}
+
+// Evaluate compares machine suggestions against the human labels of an
+// exported report
+func Evaluate(reports []report.Report, opts Options) (Result, error) {
+	if opts.Target != TargetMatch && opts.Target != TargetSimilar {
+		return Result{}, fmt.Errorf("unknown target %q, expected %q or %q", opts.Target, TargetMatch, TargetSimilar)
+	}
+	thresholds := opts.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = DefaultThresholds
+	}
+
+	res := Result{Target: opts.Target, Threshold: opts.Threshold, Products: len(reports), LeftOut: make(map[string]int)}
+	var production map[string]candidate.Candidate
+	if opts.Production != nil {
+		production = productionFlags(opts.Production)
+		res.Production = &ProductionStat{Provider: candidate.ProviderLocal}
+	}
+	var samples []sample
+	for i := range reports {
+		for _, provider := range candidate.Providers {
+			for _, c := range reports[i].LabeledCandidates(provider) {
+				positive := labeled(c, opts.Target)
+				if c.HasSimilarity {
+					samples = append(samples, sample{report: &reports[i], candidate: c, positive: positive})
+					continue
+				}
+				flags, ok := production[reports[i].Key()+"/"+c.ProductID]
+				if !ok || provider != candidate.ProviderLocal {
+					res.LeftOut[provider]++
+					continue
+				}
+				res.Production.Candidates++
+				res.Production.Confusion.add(labeled(flags, opts.Target), positive)
+			}
+		}
+	}
+	res.Scored = len(samples)
+
+	res.Matrix = confusionAt(samples, opts.Threshold)
+	for _, t := range thresholds {
+		res.Sweep = append(res.Sweep, ThresholdStat{Threshold: t, Confusion: confusionAt(samples, t)})
+	}
+
+	for _, s := range samples {
+		suggested := s.candidate.Similarity >= opts.Threshold
+		if suggested == s.positive {
+			continue
+		}
+		d := Disagreement{
+			ProductID:  s.report.ProductID,
+			ShopID:     s.report.ShopID,
+			QueryImage: s.report.ImageURL,
+			Kind:       "false negative",
+			Candidate:  s.candidate,
+			Distance:   math.Abs(s.candidate.Similarity - opts.Threshold),
+		}
+		if suggested {
+			d.Kind = "false positive"
+		}
+		res.Disagreements = append(res.Disagreements, d)
+	}
+	sort.SliceStable(res.Disagreements, func(i, j int) bool {
+		return res.Disagreements[i].Distance > res.Disagreements[j].Distance
+	})
+	if opts.Worst > 0 && len(res.Disagreements) > opts.Worst {
+		res.Disagreements = res.Disagreements[:opts.Worst]
+	}
+
+	return res, nil
+}
+
+// productionFlags indexes the local candidates of the reports as generated
+// by report key and candidate product ID
+func productionFlags(reports []report.Report) map[string]candidate.Candidate {
+	out := make(map[string]candidate.Candidate)
+	for _, r := range reports {
+		for _, c := range r.LabeledCandidates(candidate.ProviderLocal) {
+			out[r.Key()+"/"+c.ProductID] = c
+		}
+	}
+	return out
+}
+
+// labeled reports whether the Matching/Similar flags of c hit target
+func labeled(c candidate.Candidate, target string) bool {
+	if target == TargetSimilar {
+		return c.Matching || c.Similar
+	}
+	return c.Matching
+}
+
+func (c *Confusion) add(suggested, positive bool) {
+	switch {
+	case suggested && positive:
+		c.TruePositive++
+	case suggested && !positive:
+		c.FalsePositive++
+	case !suggested && positive:
+		c.FalseNegative++
+	default:
+		c.TrueNegative++
+	}
+}
+
+func confusionAt(samples []sample, threshold float64) Confusion {
+	var c Confusion
+	for _, s := range samples {
+		c.add(s.candidate.Similarity >= threshold, s.positive)
+	}
+	return c
+}
+
+// LeftOutText lists the providers left out of the evaluation, e.g.
+// "aliexpress 12, local 30", empty when none were
+func (r Result) LeftOutText() string {
+	var parts []string
+	for _, provider := range candidate.Providers {
+		if n := r.LeftOut[provider]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", provider, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// WriteText prints a plain-text summary of the evaluation
+func WriteText(w io.Writer, res Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Target:\t%s\n", res.Target)
+	fmt.Fprintf(tw, "Products:\t%d\n", res.Products)
+	fmt.Fprintf(tw, "Scored candidates:\t%d (similarity score, AliHunter only)\n", res.Scored)
+	if left := res.LeftOutText(); left != "" {
+		fmt.Fprintf(tw, "Left out:\t%s (no similarity score or production flag)\n", left)
+	}
+	if p := res.Production; p != nil {
+		c := p.Confusion
+		fmt.Fprintf(tw, "\nProduction flags (%s, %d candidates)\tPrecision %.3f\tRecall %.3f\tF1 %.3f\tTP %d\tFP %d\tFN %d\tTN %d\n",
+			p.Provider, p.Candidates, c.Precision(), c.Recall(), c.F1(), c.TruePositive, c.FalsePositive, c.FalseNegative, c.TrueNegative)
+	}
+	fmt.Fprintf(tw, "\nConfusion matrix @ %.2f\tlabel +\tlabel -\n", res.Threshold)
+	fmt.Fprintf(tw, "suggested +\t%d\t%d\n", res.Matrix.TruePositive, res.Matrix.FalsePositive)
+	fmt.Fprintf(tw, "suggested -\t%d\t%d\n", res.Matrix.FalseNegative, res.Matrix.TrueNegative)
+
+	fmt.Fprintf(tw, "\nThreshold\tPrecision\tRecall\tF1\tTP\tFP\tFN\tTN\n")
+	for _, s := range res.Sweep {
+		c := s.Confusion
+		fmt.Fprintf(tw, "%.2f\t%.3f\t%.3f\t%.3f\t%d\t%d\t%d\t%d\n", s.Threshold, c.Precision(), c.Recall(), c.F1(),
+			c.TruePositive, c.FalsePositive, c.FalseNegative, c.TrueNegative)
+	}
+
+	if len(res.Disagreements) > 0 {
+		fmt.Fprintf(tw, "\nWorst disagreements\n")
+		fmt.Fprintf(tw, "Kind\tScore\tProduct\tCandidate\tTitle\n")
+		for _, d := range res.Disagreements {
+			fmt.Fprintf(tw, "%s\t%.3f\t%d\t%s:%s\t%s\n", d.Kind, d.Candidate.Similarity, d.ProductID,
+				d.Candidate.Provider, d.Candidate.ProductID, d.Candidate.Title)
+		}
+	}
+	return tw.Flush()
+}
+
+// GenerateHTMLReport renders the evaluation, including images of the worst
+// disagreements, to a single HTML file
+func GenerateHTMLReport(res Result, outPath string) error {
+	funcMap := template.FuncMap{
+		"pct": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	}
+	t, err := templates.Load("accuracy.tmpl", "", funcMap)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", outPath, err)
+	}
+	defer f.Close()
+
+	data := struct {
+		GeneratedAt string
+		Result
+	}{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Result:      res,
+	}
+	if err := t.Execute(f, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package accuracy
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+func TestConfusion(t *testing.T) {
+	tests := []struct {
+		name                  string
+		c                     Confusion
+		precision, recall, f1 float64
+	}{
+		{"empty", Confusion{}, 0, 0, 0},
+		{"perfect", Confusion{TruePositive: 3, TrueNegative: 5}, 1, 1, 1},
+		{"mixed", Confusion{TruePositive: 2, FalsePositive: 2, FalseNegative: 6}, 0.5, 0.25, 1.0 / 3},
+		{"nothing suggested", Confusion{FalseNegative: 4, TrueNegative: 1}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []float64{tt.c.Precision(), tt.c.Recall(), tt.c.F1()}
+			want := []float64{tt.precision, tt.recall, tt.f1}
+			for i := range got {
+				if math.Abs(got[i]-want[i]) > 1e-9 {
+					t.Errorf("precision, recall, F1 = %v, want %v", got, want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	hunter := func(id, score string, matching, similar bool) model.AliHunterProduct {
+		return model.AliHunterProduct{ProductID: id, SimilarityScore: score, Matching: matching, Similar: similar}
+	}
+	reports := []report.Report{{
+		ProductID: 1,
+		ShopID:    10,
+		AliHunterTop: []model.AliHunterProduct{
+			hunter("a", "0.9", true, false),  // true positive
+			hunter("b", "95", false, false),  // false positive, score as a percentage
+			hunter("c", "0.6", true, false),  // false negative
+			hunter("d", "0.5", false, true),  // true negative for match, false negative for similar
+			hunter("e", "", true, false),     // no score, left out
+			hunter("a", "0.9", false, false), // duplicate of a
+		},
+		AliExpressTop:       []model.AliExpressProduct{{ProductID: "x"}},
+		LocalRapidAPIOrigin: []model.ProductItem{{ProductID: "l"}},
+	}}
+
+	tests := []struct {
+		target string
+		matrix Confusion
+		worst  []string // product IDs of the disagreements, furthest first
+	}{
+		{TargetMatch, Confusion{TruePositive: 1, FalsePositive: 1, FalseNegative: 1, TrueNegative: 1}, []string{"c", "b"}},
+		{TargetSimilar, Confusion{TruePositive: 1, FalsePositive: 1, FalseNegative: 2}, []string{"d", "c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			res, err := Evaluate(reports, Options{Target: tt.target, Threshold: 0.8, Thresholds: []float64{0.5, 0.95}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Products != 1 || res.Scored != 4 {
+				t.Errorf("products %d, scored %d, want 1 and 4", res.Products, res.Scored)
+			}
+			if want := map[string]int{"alihunter": 1, "aliexpress": 1, "local": 1}; !reflect.DeepEqual(res.LeftOut, want) {
+				t.Errorf("left out %v, want %v", res.LeftOut, want)
+			}
+			if res.Matrix != tt.matrix {
+				t.Errorf("matrix %+v, want %+v", res.Matrix, tt.matrix)
+			}
+			if len(res.Sweep) != 2 || res.Sweep[1].Threshold != 0.95 || res.Sweep[1].Confusion.FalsePositive != 1 {
+				t.Errorf("sweep %+v", res.Sweep)
+			}
+			var worst []string
+			for _, d := range res.Disagreements {
+				worst = append(worst, d.Candidate.ProductID)
+				if d.ProductID != 1 || d.ShopID != 10 {
+					t.Errorf("disagreement %s of product %d shop %d", d.Candidate.ProductID, d.ProductID, d.ShopID)
+				}
+			}
+			if !reflect.DeepEqual(worst, tt.worst) {
+				t.Errorf("disagreements %v, want %v", worst, tt.worst)
+			}
+		})
+	}
+}
+
+func TestEvaluateWorst(t *testing.T) {
+	r := report.Report{AliHunterTop: []model.AliHunterProduct{
+		{ProductID: "a", SimilarityScore: "0.1", Matching: true},
+		{ProductID: "b", SimilarityScore: "0.99"},
+		{ProductID: "c", SimilarityScore: "0.7", Matching: true},
+	}}
+	res, err := Evaluate([]report.Report{r}, Options{Target: TargetMatch, Threshold: 0.8, Worst: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Disagreements) != 2 || res.Disagreements[0].Candidate.ProductID != "a" || res.Disagreements[0].Kind != "false negative" {
+		t.Errorf("disagreements %+v", res.Disagreements)
+	}
+	if len(res.Sweep) != len(DefaultThresholds) {
+		t.Errorf("sweep of %d thresholds, want the %d defaults", len(res.Sweep), len(DefaultThresholds))
+	}
+}
+
+func TestEvaluateProduction(t *testing.T) {
+	labeled := []report.Report{{ProductID: 1, LocalRapidAPIOrigin: []model.ProductItem{
+		{ProductID: "a", Matching: true},
+		{ProductID: "b"},
+		{ProductID: "c", Matching: true},
+		{ProductID: "new"}, // not in the production report
+	}}}
+	production := []report.Report{{ProductID: 1, LocalRapidAPIOrigin: []model.ProductItem{
+		{ProductID: "a", Matching: true},
+		{ProductID: "b", Matching: true},
+		{ProductID: "c"},
+	}}}
+	res, err := Evaluate(labeled, Options{Target: TargetMatch, Threshold: 0.8, Production: production})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ProductionStat{Provider: "local", Candidates: 3, Confusion: Confusion{TruePositive: 1, FalsePositive: 1, FalseNegative: 1}}
+	if !reflect.DeepEqual(res.Production, want) {
+		t.Errorf("production %+v, want %+v", res.Production, want)
+	}
+	if res.LeftOut["local"] != 1 {
+		t.Errorf("left out %v, want the local candidate missing from production", res.LeftOut)
+	}
+}
+
+func TestEvaluateTarget(t *testing.T) {
+	if _, err := Evaluate(nil, Options{Target: "exact"}); err == nil {
+		t.Error("Evaluate accepted an unknown target")
+	}
+}
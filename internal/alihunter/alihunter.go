@@ -2,16 +2,18 @@ package alihunter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/httpclient"
 	"github.com/quanghia24/letsgo/internal/model"
 )
 
 const (
-	ServiceURL  = "https://product-source-api.staging.alihunter.io/aliexpress/api/products/ds-image-search-v2"
-	MaxProducts = 3
+	ServiceURL = "https://product-source-api.staging.alihunter.io/aliexpress/api/products/ds-image-search-v2"
 )
 
 type AliHunterSearchByImageRequest struct {
@@ -22,13 +24,18 @@ type AliHunterSearchByImageRequest struct {
 	ShipTo     string `json:"ship_to"`
 }
 
-// AliHunterSearchByImage fetches product data from alihunter API
-func AliHunterSearchByImage(url string) ([]model.AliHunterProduct, []model.AliHunterProduct, error) {
+// AliHunterSearchByImage fetches product data from alihunter API.
+// It returns every result with an image in upstream order; callers filter,
+// rank and cut them to report.TopN.
+func AliHunterSearchByImage(ctx context.Context, url string) (products []model.AliHunterProduct, err error) {
 	// Validate input
 	if url == "" {
-		return nil, nil, fmt.Errorf("image URL cannot be empty")
+		return nil, fmt.Errorf("image URL cannot be empty")
 	}
 
+	call := httpclient.Start(candidate.ProviderAliHunter)
+	defer func() { call.End(err) }()
+
 	arg := AliHunterSearchByImageRequest{
 		ImageURL:   url,
 		SearchType: "same",
@@ -39,56 +46,41 @@ func AliHunterSearchByImage(url string) ([]model.AliHunterProduct, []model.AliHu
 
 	body, err := json.Marshal(arg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to marshal request body: %w", err)
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, ServiceURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ServiceURL, bytes.NewReader(body))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to perform request: %w", err)
+		return nil, fmt.Errorf("failed to perform request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check HTTP status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return nil, err
 	}
 
 	var data model.AliHunterSearchByImageResponse
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	// Filter and collect products
-	var products []model.AliHunterProduct
+	// Collect products that can be displayed
 	var originProducts []model.AliHunterProduct
-
 	for _, item := range data.Result.Data.Data {
 		// Skip products without image URL
 		if item.ProductMainImageURL == "" {
 			continue
 		}
 		originProducts = append(originProducts, item)
-
-		// Filter out products with missing rating
-		if item.EvaluateRate == "" {
-			continue
-		}
-		products = append(products, item)
-
-		if len(products) >= MaxProducts {
-			break
-		}
-	}
-
-	if len(originProducts) > MaxProducts {
-		originProducts = originProducts[:3]
 	}
 
-	return products, originProducts, nil
+	call.Results(len(originProducts))
+	return originProducts, nil
 }
@@ -0,0 +1,168 @@
+package candidate
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// Provider names, matching the data-col values used by the HTML report
+const (
+	ProviderLocal      = "local"
+	ProviderAliHunter  = "alihunter"
+	ProviderAliExpress = "aliexpress"
+)
+
+// Providers lists every provider in report column order
+var Providers = []string{ProviderLocal, ProviderAliHunter, ProviderAliExpress}
+
+// Candidate is a provider-independent view of a single search result, with
+// prices, ratings and counters parsed into numbers
+type Candidate struct {
+	Provider      string
+	ProductID     string
+	Title         string
+	ImageURL      string
+	URL           string
+	Price         float64 // sale price in dollars
+	Rating        float64 // 0-5 stars
+	HasRating     bool    // the provider sent a rating, whatever its value
+	Volume        int64
+	Reviews       int64
+	HasReviews    bool    // the review count is known, a failed lookup leaves it unknown
+	Similarity    float64 // 0-1, only AliHunter reports it
+	HasSimilarity bool
+	ShipFrom      string
+	Matching      bool
+	Similar       bool
+	Reviewed      bool // Matching and Similar were set by a reviewer, only tracked for local
+}
+
+// FromAliHunter converts an AliHunter search result
+func FromAliHunter(p model.AliHunterProduct) Candidate {
+	c := Candidate{
+		Provider:   ProviderAliHunter,
+		ProductID:  p.ProductID,
+		Title:      p.ProductTitle,
+		ImageURL:   p.ProductMainImageURL,
+		URL:        p.ProductDetailURL,
+		Volume:     parseInt(p.LatestVolume),
+		Reviews:    ParseReviews(p.TotalReview),
+		HasReviews: strings.TrimSpace(p.TotalReview) != "",
+		ShipFrom:   p.ShipFrom,
+		Matching:   p.Matching,
+		Similar:    p.Similar,
+	}
+	// AliHunter prices are expressed in cents
+	if cents, err := strconv.ParseFloat(strings.TrimSpace(p.TargetSalePrice), 64); err == nil {
+		c.Price = cents / 100
+	}
+	// A rating counts as present as soon as the field is set, the parsed
+	// value only feeds the score
+	if p.EvaluateRate != "" {
+		c.Rating, _ = parseRate(p.EvaluateRate)
+		c.HasRating = true
+	}
+	if score, err := strconv.ParseFloat(strings.TrimSpace(p.SimilarityScore), 64); err == nil {
+		if score > 1 { // percentage
+			score /= 100
+		}
+		c.Similarity, c.HasSimilarity = score, true
+	}
+	return c
+}
+
+// FromAliExpress converts a RapidAPI AliExpress search result
+func FromAliExpress(p model.AliExpressProduct) Candidate {
+	return Candidate{
+		Provider:   ProviderAliExpress,
+		ProductID:  p.ProductID,
+		Title:      p.Title,
+		ImageURL:   NormalizeURL(p.ImageURL),
+		URL:        NormalizeURL(p.URL),
+		Price:      p.SalePrice,
+		Rating:     p.AvgRatingStar,
+		HasRating:  p.HasRating,
+		Volume:     p.Volume,
+		Reviews:    ParseReviews(p.TotalReview),
+		HasReviews: strings.TrimSpace(p.TotalReview) != "",
+		Matching:   p.Matching,
+		Similar:    p.Similar,
+	}
+}
+
+// FromLocal converts a product stored by the production suggestion engine
+func FromLocal(p model.ProductItem) Candidate {
+	return Candidate{
+		Provider:   ProviderLocal,
+		ProductID:  p.ProductID,
+		Title:      p.ProductTitle,
+		ImageURL:   p.ProductMainImageURL,
+		URL:        p.ProductURL,
+		Price:      ParsePrice(p.TargetSalePrice),
+		Rating:     p.AvgStar,
+		HasRating:  p.AvgStar > 0,
+		Volume:     int64(p.Sale),
+		Reviews:    p.TotalReview,
+		HasReviews: true,
+		Matching:   p.Matching,
+		Similar:    p.Similar,
+		Reviewed:   p.Reviewed,
+	}
+}
+
+// ParsePrice parses prices such as "$11.75" or "11.75 USD", returning 0 when
+// no number can be found
+func ParsePrice(s string) float64 {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "US")
+	s = strings.TrimPrefix(s, "$")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	v, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return v
+}
+
+// ParseReviews parses review counts formatted by report.GetReviewsCount, e.g.
+// "46 ratings"
+func ParseReviews(s string) int64 {
+	if i := strings.IndexByte(strings.TrimSpace(s), ' '); i >= 0 {
+		s = strings.TrimSpace(s)[:i]
+	}
+	return parseInt(s)
+}
+
+// NormalizeURL turns protocol-relative URLs returned by AliExpress into
+// absolute https URLs
+func NormalizeURL(u string) string {
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+	return u
+}
+
+func parseInt(s string) int64 {
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return int64(f)
+	}
+	return v
+}
+
+// parseRate handles both star ratings ("4.7") and AliExpress positive
+// feedback percentages ("94.5%"), which are mapped onto 0-5 stars
+func parseRate(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	pct := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, false
+	}
+	if pct {
+		v /= 20
+	}
+	return v, true
+}
@@ -0,0 +1,294 @@
+package diff
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/report"
+	"github.com/quanghia24/letsgo/internal/templates"
+)
+
+// ProductRef identifies an input product across runs
+type ProductRef struct {
+	ShopID    int64
+	ProductID int64
+	Title     string
+	ImageURL  string
+}
+
+// CandidateChange records how a candidate present in both runs moved
+type CandidateChange struct {
+	Candidate     candidate.Candidate // as seen in the new run
+	OldPrice      float64
+	OldReviews    int64
+	OldHasReviews bool
+	OldMatching   bool
+	OldSimilar    bool
+}
+
+func (c CandidateChange) PriceChanged() bool { return c.OldPrice != c.Candidate.Price }
+
+// ReviewsChanged reports a different review count, or one that became known
+// or unknown; see ReviewsCompared
+func (c CandidateChange) ReviewsChanged() bool {
+	return c.OldHasReviews != c.Candidate.HasReviews || c.OldReviews != c.Candidate.Reviews
+}
+
+// ReviewsCompared reports whether both review counts are known, so the
+// change is a rise or drop
+func (c CandidateChange) ReviewsCompared() bool {
+	return c.OldHasReviews && c.Candidate.HasReviews
+}
+func (c CandidateChange) LabelChanged() bool {
+	return c.OldMatching != c.Candidate.Matching || c.OldSimilar != c.Candidate.Similar
+}
+
+// ProviderDiff lists the differences of one provider column for a product
+type ProviderDiff struct {
+	Provider    string
+	OldTop      []candidate.Candidate
+	NewTop      []candidate.Candidate
+	TopChanged  bool
+	Disappeared []candidate.Candidate
+	Appeared    []candidate.Candidate
+	Changes     []CandidateChange
+}
+
+func (p ProviderDiff) empty() bool {
+	return !p.TopChanged && len(p.Disappeared) == 0 && len(p.Appeared) == 0 && len(p.Changes) == 0
+}
+
+// ProductDiff lists the provider differences of a product present in both runs
+type ProductDiff struct {
+	ProductRef
+	Providers []ProviderDiff
+}
+
+// Result is the outcome of comparing two reports
+type Result struct {
+	OldPath  string
+	NewPath  string
+	Compared int
+	Added    []ProductRef // only in the new report
+	Removed  []ProductRef // only in the old report
+	Products []ProductDiff
+}
+
+func refOf(r report.Report) ProductRef {
+	return ProductRef{ShopID: r.ShopID, ProductID: r.ProductID, Title: r.ProductTitle, ImageURL: r.ImageURL}
+}
+
+// Compare reports what changed between two runs. Products are matched by
+// report.Key, candidates by provider product ID.
+func Compare(oldReports, newReports []report.Report) Result {
+	var res Result
+
+	oldByKey := make(map[string]report.Report, len(oldReports))
+	for _, r := range oldReports {
+		oldByKey[r.Key()] = r
+	}
+	seen := make(map[string]bool, len(newReports))
+
+	for _, nr := range newReports {
+		k := nr.Key()
+		seen[k] = true
+		or, ok := oldByKey[k]
+		if !ok {
+			res.Added = append(res.Added, refOf(nr))
+			continue
+		}
+		res.Compared++
+
+		pd := ProductDiff{ProductRef: refOf(nr)}
+		for _, provider := range candidate.Providers {
+			if d := compareProvider(provider, or, nr); !d.empty() {
+				pd.Providers = append(pd.Providers, d)
+			}
+		}
+		if len(pd.Providers) > 0 {
+			res.Products = append(res.Products, pd)
+		}
+	}
+
+	for _, or := range oldReports {
+		if !seen[or.Key()] {
+			res.Removed = append(res.Removed, refOf(or))
+		}
+	}
+	return res
+}
+
+func compareProvider(provider string, or, nr report.Report) ProviderDiff {
+	d := ProviderDiff{
+		Provider: provider,
+		OldTop:   or.Candidates(provider, false),
+		NewTop:   nr.Candidates(provider, false),
+	}
+	d.TopChanged = !sameIDs(d.OldTop, d.NewTop)
+
+	oldAll := or.LabeledCandidates(provider)
+	newAll := nr.LabeledCandidates(provider)
+	newByID := make(map[string]candidate.Candidate, len(newAll))
+	for _, c := range newAll {
+		newByID[c.ProductID] = c
+	}
+	oldByID := make(map[string]candidate.Candidate, len(oldAll))
+	for _, c := range oldAll {
+		oldByID[c.ProductID] = c
+		nc, ok := newByID[c.ProductID]
+		if !ok {
+			d.Disappeared = append(d.Disappeared, c)
+			continue
+		}
+		change := CandidateChange{
+			Candidate:     nc,
+			OldPrice:      c.Price,
+			OldReviews:    c.Reviews,
+			OldHasReviews: c.HasReviews,
+			OldMatching:   c.Matching,
+			OldSimilar:    c.Similar,
+		}
+		if change.PriceChanged() || change.ReviewsChanged() || change.LabelChanged() {
+			d.Changes = append(d.Changes, change)
+		}
+	}
+	for _, c := range newAll {
+		if _, ok := oldByID[c.ProductID]; !ok {
+			d.Appeared = append(d.Appeared, c)
+		}
+	}
+	return d
+}
+
+func sameIDs(a, b []candidate.Candidate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ProductID != b[i].ProductID {
+			return false
+		}
+	}
+	return true
+}
+
+func ids(cs []candidate.Candidate) []string {
+	out := make([]string, 0, len(cs))
+	for _, c := range cs {
+		out = append(out, c.ProductID)
+	}
+	return out
+}
+
+// errWriter keeps the first error of its writes, so a sequence of writes
+// only needs checking once
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
+
+// WriteText prints the differences in a plain-text form
+func WriteText(w io.Writer, res Result) error {
+	ew := &errWriter{w: w}
+	ew.printf("Old: %s\nNew: %s\n", res.OldPath, res.NewPath)
+	ew.printf("Compared %d products, %d changed, %d added, %d removed\n",
+		res.Compared, len(res.Products), len(res.Added), len(res.Removed))
+
+	for _, p := range res.Added {
+		ew.printf("\n+ product %d (shop %d) %s\n", p.ProductID, p.ShopID, p.Title)
+	}
+	for _, p := range res.Removed {
+		ew.printf("\n- product %d (shop %d) %s\n", p.ProductID, p.ShopID, p.Title)
+	}
+
+	for _, p := range res.Products {
+		ew.printf("\n~ product %d (shop %d) %s\n", p.ProductID, p.ShopID, p.Title)
+		for _, d := range p.Providers {
+			ew.printf("  [%s]\n", d.Provider)
+			if d.TopChanged {
+				ew.printf("    top: %v -> %v\n", ids(d.OldTop), ids(d.NewTop))
+			}
+			for _, c := range d.Disappeared {
+				ew.printf("    disappeared: %s %s\n", c.ProductID, c.Title)
+			}
+			for _, c := range d.Appeared {
+				ew.printf("    appeared: %s %s\n", c.ProductID, c.Title)
+			}
+			for _, c := range d.Changes {
+				ew.printf("    %s:", c.Candidate.ProductID)
+				if c.PriceChanged() {
+					ew.printf(" price $%.2f -> $%.2f", c.OldPrice, c.Candidate.Price)
+				}
+				if c.ReviewsChanged() {
+					ew.printf(" reviews %s -> %s", reviews(c.OldReviews, c.OldHasReviews), reviews(c.Candidate.Reviews, c.Candidate.HasReviews))
+				}
+				if c.LabelChanged() {
+					ew.printf(" labels %s -> %s", labels(c.OldMatching, c.OldSimilar), labels(c.Candidate.Matching, c.Candidate.Similar))
+				}
+				ew.printf("\n")
+			}
+		}
+	}
+	return ew.err
+}
+
+// reviews formats a review count, which a failed lookup leaves unknown
+func reviews(n int64, known bool) string {
+	if !known {
+		return "unknown"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func labels(matching, similar bool) string {
+	switch {
+	case matching && similar:
+		return "match+similar"
+	case matching:
+		return "match"
+	case similar:
+		return "similar"
+	}
+	return "none"
+}
+
+// GenerateHTMLReport writes the differences as a single HTML page
+func GenerateHTMLReport(res Result, outPath string) error {
+	funcMap := template.FuncMap{
+		"labels":  labels,
+		"reviews": reviews,
+	}
+	t, err := templates.Load("diff.tmpl", "", funcMap)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", outPath, err)
+	}
+	defer f.Close()
+
+	data := struct {
+		GeneratedAt string
+		Result
+	}{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Result:      res,
+	}
+	if err := t.Execute(f, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+func TestCompareProducts(t *testing.T) {
+	rep := func(suggestionID string, shopID, productID int64) report.Report {
+		return report.Report{SuggestionID: suggestionID, ShopID: shopID, ProductID: productID}
+	}
+	tests := []struct {
+		name           string
+		old, new       []report.Report
+		compared       int
+		added, removed []int64 // product IDs added and removed
+	}{
+		{"same products", []report.Report{rep("", 1, 10)}, []report.Report{rep("", 1, 10)}, 1, nil, nil},
+		{"added and removed", []report.Report{rep("", 1, 10)}, []report.Report{rep("", 1, 11)}, 0, []int64{11}, []int64{10}},
+		{"same product in another shop", []report.Report{rep("", 1, 10)}, []report.Report{rep("", 2, 10)}, 0, []int64{10}, []int64{10}},
+		{"matched by suggestion", []report.Report{rep("a", 1, 10)}, []report.Report{rep("a", 1, 10)}, 1, nil, nil},
+		{"two suggestions of a product", []report.Report{rep("a", 1, 10)}, []report.Report{rep("a", 1, 10), rep("b", 1, 10)}, 1, []int64{10}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Compare(tt.old, tt.new)
+			if res.Compared != tt.compared {
+				t.Errorf("compared %d, want %d", res.Compared, tt.compared)
+			}
+			if got := productIDs(res.Added); !reflect.DeepEqual(got, tt.added) {
+				t.Errorf("added %v, want %v", got, tt.added)
+			}
+			if got := productIDs(res.Removed); !reflect.DeepEqual(got, tt.removed) {
+				t.Errorf("removed %v, want %v", got, tt.removed)
+			}
+		})
+	}
+}
+
+func TestCompareCandidates(t *testing.T) {
+	item := func(id, cents, reviews string) model.AliHunterProduct {
+		return model.AliHunterProduct{ProductID: id, TargetSalePrice: cents, TotalReview: reviews}
+	}
+	tests := []struct {
+		name     string
+		old, new []model.AliHunterProduct
+		want     string // WriteText lines of the provider, without the header
+	}{
+		{"unchanged", []model.AliHunterProduct{item("1", "500", "4 ratings")}, []model.AliHunterProduct{item("1", "500", "4 ratings")}, ""},
+		{"price", []model.AliHunterProduct{item("1", "500", "4 ratings")}, []model.AliHunterProduct{item("1", "650", "4 ratings")}, "    1: price $5.00 -> $6.50\n"},
+		{"reviews", []model.AliHunterProduct{item("1", "500", "4 ratings")}, []model.AliHunterProduct{item("1", "500", "0 ratings")}, "    1: reviews 4 -> 0\n"},
+		{"failed reviews lookup", []model.AliHunterProduct{item("1", "500", "4 ratings")}, []model.AliHunterProduct{item("1", "500", "")}, "    1: reviews 4 -> unknown\n"},
+		{"reviews found again", []model.AliHunterProduct{item("1", "500", "")}, []model.AliHunterProduct{item("1", "500", "4 ratings")}, "    1: reviews unknown -> 4\n"},
+		{"appeared", nil, []model.AliHunterProduct{item("2", "500", "")}, "    top: [] -> [2]\n    appeared: 2 \n"},
+		{"disappeared", []model.AliHunterProduct{item("2", "500", "")}, nil, "    top: [2] -> []\n    disappeared: 2 \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			or := report.Report{ShopID: 1, ProductID: 10, AliHunterTop: tt.old}
+			nr := report.Report{ShopID: 1, ProductID: 10, AliHunterTop: tt.new}
+			res := Compare([]report.Report{or}, []report.Report{nr})
+			var b strings.Builder
+			if err := WriteText(&b, res); err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if _, rest, ok := strings.Cut(b.String(), "[alihunter]\n"); ok {
+				got = rest
+			}
+			if got != tt.want {
+				t.Errorf("WriteText wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{ after int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.after == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.after--
+	return len(p), nil
+}
+
+func TestWriteTextError(t *testing.T) {
+	res := Result{Added: []ProductRef{{ShopID: 1, ProductID: 10}}, Removed: []ProductRef{{ShopID: 1, ProductID: 11}}}
+	for after := range 4 {
+		w := &failingWriter{after: after}
+		if err := WriteText(w, res); err == nil {
+			t.Errorf("write %d failed, WriteText returned nil", after+1)
+		}
+	}
+}
+
+func productIDs(refs []ProductRef) []int64 {
+	var ids []int64
+	for _, r := range refs {
+		ids = append(ids, r.ProductID)
+	}
+	return ids
+}
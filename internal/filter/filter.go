@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+)
+
+// Rules decide which candidates make it into a provider's "Filtered" column.
+// Zero values disable a rule.
+type Rules struct {
+	RequireImage   bool     `json:"require_image"`
+	RequireRating  bool     `json:"require_rating"`
+	MinRating      float64  `json:"min_rating"`
+	MinVolume      int64    `json:"min_volume"`
+	MinReviews     int64    `json:"min_reviews"`
+	MinPrice       float64  `json:"min_price"`
+	MaxPrice       float64  `json:"max_price"`
+	ShipFrom       []string `json:"ship_from"`       // allowed countries; candidates without ship-from data pass
+	TitleBlocklist []string `json:"title_blocklist"` // case-insensitive keywords
+}
+
+// Config holds the default rules and per-provider replacements
+type Config struct {
+	Default   Rules            `json:"default"`
+	Providers map[string]Rules `json:"providers"`
+}
+
+// DefaultConfig reproduces the historical behaviour: providers drop items
+// without image or rating, local results only need an image
+var DefaultConfig = Config{
+	Default: Rules{RequireImage: true, RequireRating: true},
+	Providers: map[string]Rules{
+		candidate.ProviderLocal: {RequireImage: true},
+	},
+}
+
+// For returns the rules applied to a provider
+func (c Config) For(provider string) Rules {
+	if r, ok := c.Providers[provider]; ok {
+		return r
+	}
+	return c.Default
+}
+
+// Exclusion records why a candidate was left out of the filtered column
+type Exclusion struct {
+	ProductID string `json:"product_id"`
+	Rule      string `json:"rule"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Check returns the first rule the candidate violates, if any
+func (r Rules) Check(c candidate.Candidate) (Exclusion, bool) {
+	excluded := func(rule, detail string) (Exclusion, bool) {
+		return Exclusion{ProductID: c.ProductID, Rule: rule, Detail: detail}, false
+	}
+
+	if r.RequireImage && c.ImageURL == "" {
+		return excluded("require_image", "")
+	}
+	if r.RequireRating && !c.HasRating {
+		return excluded("require_rating", "")
+	}
+	if r.MinRating > 0 && c.Rating < r.MinRating {
+		return excluded("min_rating", fmt.Sprintf("%.1f < %.1f", c.Rating, r.MinRating))
+	}
+	if r.MinVolume > 0 && c.Volume < r.MinVolume {
+		return excluded("min_volume", fmt.Sprintf("%d < %d", c.Volume, r.MinVolume))
+	}
+	if r.MinReviews > 0 && c.Reviews < r.MinReviews {
+		return excluded("min_reviews", fmt.Sprintf("%d < %d", c.Reviews, r.MinReviews))
+	}
+	if r.MinPrice > 0 && c.Price < r.MinPrice {
+		return excluded("min_price", fmt.Sprintf("$%.2f < $%.2f", c.Price, r.MinPrice))
+	}
+	if r.MaxPrice > 0 && c.Price > r.MaxPrice {
+		return excluded("max_price", fmt.Sprintf("$%.2f > $%.2f", c.Price, r.MaxPrice))
+	}
+	if len(r.ShipFrom) > 0 && c.ShipFrom != "" && !containsFold(r.ShipFrom, c.ShipFrom) {
+		return excluded("ship_from", c.ShipFrom)
+	}
+	title := strings.ToLower(c.Title)
+	for _, keyword := range r.TitleBlocklist {
+		if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+			return excluded("title_blocklist", keyword)
+		}
+	}
+	return Exclusion{}, true
+}
+
+// Apply returns the items passing the rules, in their original order, and
+// the reason each other item was dropped
+func Apply[T any](items []T, conv func(T) candidate.Candidate, r Rules) ([]T, []Exclusion) {
+	var kept []T
+	var excluded []Exclusion
+	for _, item := range items {
+		if ex, ok := r.Check(conv(item)); !ok {
+			excluded = append(excluded, ex)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept, excluded
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+)
+
+func TestCheck(t *testing.T) {
+	// good passes every rule below
+	good := candidate.Candidate{
+		ProductID: "1",
+		Title:     "Cotton T-Shirt",
+		ImageURL:  "https://img/1.jpg",
+		Rating:    4.5,
+		HasRating: true,
+		Volume:    100,
+		Reviews:   20,
+		Price:     12,
+		ShipFrom:  "CN",
+	}
+	with := func(edit func(c *candidate.Candidate)) candidate.Candidate {
+		c := good
+		edit(&c)
+		return c
+	}
+	tests := []struct {
+		name   string
+		rules  Rules
+		c      candidate.Candidate
+		rule   string // excluding rule, empty when the candidate passes
+		detail string
+	}{
+		{"no rules", Rules{}, candidate.Candidate{}, "", ""},
+		{"image", Rules{RequireImage: true}, with(func(c *candidate.Candidate) { c.ImageURL = "" }), "require_image", ""},
+		{"rating missing", Rules{RequireRating: true}, with(func(c *candidate.Candidate) { c.Rating, c.HasRating = 0, false }), "require_rating", ""},
+		{"rating of zero is present", Rules{RequireRating: true}, with(func(c *candidate.Candidate) { c.Rating = 0 }), "", ""},
+		{"min rating", Rules{MinRating: 4.8}, good, "min_rating", "4.5 < 4.8"},
+		{"min rating met", Rules{MinRating: 4.5}, good, "", ""},
+		{"min volume", Rules{MinVolume: 101}, good, "min_volume", "100 < 101"},
+		{"min reviews", Rules{MinReviews: 50}, good, "min_reviews", "20 < 50"},
+		{"min price", Rules{MinPrice: 15}, good, "min_price", "$12.00 < $15.00"},
+		{"max price", Rules{MaxPrice: 10}, good, "max_price", "$12.00 > $10.00"},
+		{"ship from allowed", Rules{ShipFrom: []string{"us", " cn "}}, good, "", ""},
+		{"ship from elsewhere", Rules{ShipFrom: []string{"US"}}, good, "ship_from", "CN"},
+		{"ship from unknown passes", Rules{ShipFrom: []string{"US"}}, with(func(c *candidate.Candidate) { c.ShipFrom = "" }), "", ""},
+		{"blocked title", Rules{TitleBlocklist: []string{"", "t-shirt"}}, good, "title_blocklist", "t-shirt"},
+		{"first rule wins", Rules{RequireImage: true, MaxPrice: 10}, with(func(c *candidate.Candidate) { c.ImageURL = "" }), "require_image", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ex, ok := tt.rules.Check(tt.c)
+			if tt.rule == "" {
+				if !ok {
+					t.Errorf("excluded by %s (%s), want it kept", ex.Rule, ex.Detail)
+				}
+				return
+			}
+			want := Exclusion{ProductID: tt.c.ProductID, Rule: tt.rule, Detail: tt.detail}
+			if ok || ex != want {
+				t.Errorf("Check = %+v, %v, want %+v", ex, ok, want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	conv := func(price float64) candidate.Candidate { return candidate.Candidate{Price: price} }
+	kept, excluded := Apply([]float64{5, 30, 8, 50}, conv, Rules{MaxPrice: 20})
+	if want := []float64{5, 8}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %v, want %v", kept, want)
+	}
+	if len(excluded) != 2 || excluded[0].Detail != "$30.00 > $20.00" || excluded[1].Detail != "$50.00 > $20.00" {
+		t.Errorf("excluded %+v", excluded)
+	}
+}
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     Rules
+	}{
+		{candidate.ProviderLocal, Rules{RequireImage: true}},
+		{candidate.ProviderAliHunter, Rules{RequireImage: true, RequireRating: true}},
+		{candidate.ProviderAliExpress, Rules{RequireImage: true, RequireRating: true}},
+	}
+	for _, tt := range tests {
+		if got := DefaultConfig.For(tt.provider); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DefaultConfig.For(%q) = %+v, want %+v", tt.provider, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package httpclient holds what the provider clients share about HTTP calls.
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client makes every provider call. Its Timeout bounds a whole call, reading
+// the body included, and is counted as a timeout in the metrics; the
+// commands set it from -call-timeout.
+var Client = &http.Client{Timeout: 30 * time.Second}
+
+// StatusError is returned when an API answers with a status other than 200
+type StatusError struct {
+	Code   int
+	Status string // e.g. "429 Too Many Requests"
+	Body   string // start of the response body
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status %s", e.Status)
+	}
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+// CheckStatus returns a *StatusError for any response that is not 200 OK
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Code: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+}
+
+// StatusCode returns the HTTP status carried by err, 0 when there is none
+func StatusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code
+	}
+	return 0
+}
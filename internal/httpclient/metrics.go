@@ -0,0 +1,254 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProviderReviews names the AliExpress feedback pages in metrics. The search
+// providers are named by candidate.ProviderAliHunter and
+// candidate.ProviderAliExpress.
+const ProviderReviews = "aliexpress-reviews"
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histogram
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// resultBuckets are the upper bounds of the results-per-query histogram
+var resultBuckets = []float64{0, 1, 5, 10, 20, 50, 100}
+
+// metrics is what has been observed of the calls to one provider. Calls
+// cancelled by the caller are only counted, they say nothing of the provider.
+type metrics struct {
+	calls     int64
+	errors    int64
+	timeouts  int64
+	cancelled int64
+	statuses  map[int]int64 // HTTP status of failed calls, 0 when there was no response
+	latency   histogram     // seconds
+	empty     int64         // queries without results
+	results   histogram     // per query that reported a result count
+}
+
+// histogram counts observations into fixed buckets, so it stays the same
+// size however many calls a run makes
+type histogram struct {
+	bounds []float64 // upper bounds, the last bucket has none
+	counts []int64   // per bucket, not cumulative
+	count  int64
+	sum    float64
+	max    float64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(x float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, x)]++
+	h.count++
+	h.sum += x
+	h.max = max(h.max, x)
+}
+
+var (
+	mu        sync.Mutex
+	providers = make(map[string]*metrics)
+)
+
+// Call times one call to a provider. Start it before the request and End it
+// once the response is handled.
+type Call struct {
+	provider string
+	start    time.Time
+	results  int
+	counted  bool
+}
+
+// Start begins timing a call to provider
+func Start(provider string) *Call {
+	return &Call{provider: provider, start: time.Now()}
+}
+
+// Results records the number of results the call returned
+func (c *Call) Results(n int) {
+	c.results, c.counted = n, true
+}
+
+// End records the call, failed when err is not nil
+func (c *Call) End(err error) {
+	latency := time.Since(c.start)
+
+	mu.Lock()
+	defer mu.Unlock()
+	m := providers[c.provider]
+	if m == nil {
+		m = &metrics{
+			statuses: make(map[int]int64),
+			latency:  newHistogram(latencyBuckets),
+			results:  newHistogram(resultBuckets),
+		}
+		providers[c.provider] = m
+	}
+	if errors.Is(err, context.Canceled) {
+		m.cancelled++
+		return
+	}
+	m.calls++
+	m.latency.observe(latency.Seconds())
+	if err != nil {
+		m.errors++
+		m.statuses[StatusCode(err)]++
+		if IsTimeout(err) {
+			m.timeouts++
+		}
+		return
+	}
+	if c.counted {
+		m.results.observe(float64(c.results))
+		if c.results == 0 {
+			m.empty++
+		}
+	}
+}
+
+// IsTimeout reports whether err is a call that ran out of time
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// Summary is what has been observed of the calls to one provider
+type Summary struct {
+	Calls       int64           `json:"calls"`
+	Errors      int64           `json:"errors"`
+	Timeouts    int64           `json:"timeouts"`
+	Cancelled   int64           `json:"cancelled,omitempty"` // stopped by the run, not part of Calls
+	ErrorRate   float64         `json:"error_rate"`
+	TimeoutRate float64         `json:"timeout_rate"`
+	Statuses    map[int]int64   `json:"error_statuses,omitempty"` // failed calls by HTTP status, 0 without a response
+	LatencyMS   Percentiles     `json:"latency_ms"`
+	Results     *ResultsSummary `json:"results,omitempty"`
+	Latency     []BucketCount   `json:"latency_histogram"` // cumulative, upper bounds in seconds
+}
+
+// Percentiles of a distribution, estimated from its histogram buckets
+type Percentiles struct {
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// ResultsSummary describes the number of results per successful query
+type ResultsSummary struct {
+	Queries int64         `json:"queries"`
+	Empty   int64         `json:"empty"` // queries without results
+	Counts  Percentiles   `json:"per_query"`
+	Buckets []BucketCount `json:"histogram"` // cumulative
+}
+
+// BucketCount is a cumulative histogram bucket: Count observations were at
+// most Le. The last bucket has an infinite Le, written as null.
+type BucketCount struct {
+	Le    *float64 `json:"le"`
+	Count int64    `json:"count"`
+}
+
+// Snapshot summarises the calls observed so far, by provider
+func Snapshot() map[string]Summary {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make(map[string]Summary, len(providers))
+	for name, m := range providers {
+		s := Summary{
+			Calls:     m.calls,
+			Errors:    m.errors,
+			Timeouts:  m.timeouts,
+			Cancelled: m.cancelled,
+			Statuses:  copyStatuses(m.statuses),
+			LatencyMS: m.latency.percentiles(1000),
+			Latency:   m.latency.cumulative(),
+		}
+		if m.calls > 0 {
+			s.ErrorRate = float64(m.errors) / float64(m.calls)
+			s.TimeoutRate = float64(m.timeouts) / float64(m.calls)
+		}
+		if m.results.count > 0 {
+			s.Results = &ResultsSummary{
+				Queries: m.results.count,
+				Empty:   m.empty,
+				Counts:  m.results.percentiles(1),
+				Buckets: m.results.cumulative(),
+			}
+		}
+		out[name] = s
+	}
+	return out
+}
+
+func copyStatuses(m map[int]int64) map[int]int64 {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[int]int64, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// percentiles are interpolated linearly within the bucket holding the rank,
+// as Prometheus' histogram_quantile does, and never exceed the largest
+// observation. scale converts the observed unit, e.g. seconds to ms.
+func (h *histogram) percentiles(scale float64) Percentiles {
+	if h.count == 0 {
+		return Percentiles{}
+	}
+	return Percentiles{
+		P50:  h.quantile(0.5) * scale,
+		P90:  h.quantile(0.9) * scale,
+		P95:  h.quantile(0.95) * scale,
+		P99:  h.quantile(0.99) * scale,
+		Max:  h.max * scale,
+		Mean: h.sum / float64(h.count) * scale,
+	}
+}
+
+func (h *histogram) quantile(q float64) float64 {
+	rank := q * float64(h.count)
+	seen := int64(0)
+	for i, n := range h.counts {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		lower, upper := 0.0, h.max
+		if i > 0 {
+			lower = h.bounds[i-1]
+		}
+		if i < len(h.bounds) {
+			upper = min(h.bounds[i], h.max)
+		}
+		return lower + (upper-lower)*(rank-float64(seen))/float64(n)
+	}
+	return h.max
+}
+
+func (h *histogram) cumulative() []BucketCount {
+	out := make([]BucketCount, len(h.counts))
+	total := int64(0)
+	for i, n := range h.counts {
+		total += n
+		out[i].Count = total
+		if i < len(h.bounds) {
+			out[i].Le = &h.bounds[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Handler serves the provider metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the provider metrics in the Prometheus text format
+func WritePrometheus(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP letsgo_provider_requests_total Calls made to each provider.")
+	fmt.Fprintln(w, "# TYPE letsgo_provider_requests_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "letsgo_provider_requests_total{provider=%q} %d\n", name, providers[name].calls)
+	}
+
+	fmt.Fprintln(w, "# HELP letsgo_provider_errors_total Failed calls by HTTP status, 0 when there was no response.")
+	fmt.Fprintln(w, "# TYPE letsgo_provider_errors_total counter")
+	for _, name := range names {
+		m := providers[name]
+		codes := make([]int, 0, len(m.statuses))
+		for code := range m.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "letsgo_provider_errors_total{provider=%q,status=\"%d\"} %d\n", name, code, m.statuses[code])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP letsgo_provider_timeouts_total Calls that ran out of time.")
+	fmt.Fprintln(w, "# TYPE letsgo_provider_timeouts_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "letsgo_provider_timeouts_total{provider=%q} %d\n", name, providers[name].timeouts)
+	}
+
+	fmt.Fprintln(w, "# HELP letsgo_provider_cancelled_total Calls cancelled by the run, left out of the requests and errors.")
+	fmt.Fprintln(w, "# TYPE letsgo_provider_cancelled_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "letsgo_provider_cancelled_total{provider=%q} %d\n", name, providers[name].cancelled)
+	}
+
+	fmt.Fprintln(w, "# HELP letsgo_provider_request_duration_seconds Latency of the calls to each provider.")
+	fmt.Fprintln(w, "# TYPE letsgo_provider_request_duration_seconds histogram")
+	for _, name := range names {
+		writeHistogram(w, "letsgo_provider_request_duration_seconds", name, &providers[name].latency)
+	}
+
+	fmt.Fprintln(w, "# HELP letsgo_provider_results Results returned per successful query.")
+	fmt.Fprintln(w, "# TYPE letsgo_provider_results histogram")
+	for _, name := range names {
+		if h := &providers[name].results; h.count > 0 {
+			writeHistogram(w, "letsgo_provider_results", name, h)
+		}
+	}
+}
+
+func writeHistogram(w io.Writer, metric, provider string, h *histogram) {
+	for _, b := range h.cumulative() {
+		le := "+Inf"
+		if b.Le != nil {
+			le = strconv.FormatFloat(*b.Le, 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "%s_bucket{provider=%q,le=%q} %d\n", metric, provider, le, b.Count)
+	}
+	fmt.Fprintf(w, "%s_sum{provider=%q} %s\n", metric, provider, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{provider=%q} %d\n", metric, provider, h.count)
+}
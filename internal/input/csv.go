@@ -0,0 +1,160 @@
+package input
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// CSV columns, matched case-insensitively against the header row
+var csvColumns = []string{"product_id", "title", "image_url", "shop"}
+
+// IsCSV reports whether a path names a CSV file
+func IsCSV(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+// LoadCSV reads an ad-hoc batch from a "product_id,title,image_url,shop"
+// spreadsheet export. The shop column holds a shop ID or a myshopify domain;
+// see CSVShopID. Rows carry no production results, so their local column is
+// unavailable, nor a job status.
+func LoadCSV(path string) ([]model.ShopGroup, error) {
+	var groups []model.ShopGroup
+	index := make(map[int64]int)
+	domains := make(map[int64]string) // domain each derived ID came from
+	err := StreamCSV(path, func(line int, shop string, p model.SuggestionProduct, err error) error {
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		group := csvShopGroup(shop)
+		if other, ok := domains[group.ShopID]; ok && !strings.EqualFold(other, shop) {
+			return fmt.Errorf("%s:%d: shops %q and %q get the same ID %d", path, line, other, shop, group.ShopID)
+		}
+		if group.ShopID < 0 {
+			domains[group.ShopID] = shop
+		}
+		i, ok := index[group.ShopID]
+		if !ok {
+			i = len(groups)
+			index[group.ShopID] = i
+			groups = append(groups, group)
+		}
+		p.ShopID = groups[i].ShopID
+		p.Product.ShopID = p.ShopID
+		groups[i].SuggestionProducts = append(groups[i].SuggestionProducts, p)
+		groups[i].ProductCount++
+		return nil
+	})
+	return groups, err
+}
+
+// CSVShopID returns the shop ID of a CSV shop column: the number itself, or
+// for a myshopify domain a stable negative ID derived from it, so products of
+// different domain-only shops never share a report key. It is 0 when the
+// column is empty.
+func CSVShopID(shop string) int64 {
+	if shop == "" {
+		return 0
+	}
+	if id, err := strconv.ParseInt(shop, 10, 64); err == nil {
+		return id
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(shop)))
+	return -int64(h.Sum64()&math.MaxInt64) - 1
+}
+
+// StreamCSV calls fn for every data row of a CSV batch with the raw shop
+// column and the row as a suggestion product. Rows that cannot be converted
+// are passed with a non-nil err, so callers decide whether to stop.
+func StreamCSV(path string, fn func(line int, shop string, p model.SuggestionProduct, err error) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("cannot read header of %s: %w", path, err)
+	}
+	col := make(map[string]int)
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range csvColumns[:3] { // shop is optional
+		if _, ok := col[name]; !ok {
+			return fmt.Errorf("%s: missing column %q, expected %s", path, name, strings.Join(csvColumns, ","))
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var line int
+		var parseErr *csv.ParseError
+		var p model.SuggestionProduct
+		switch {
+		case errors.As(err, &parseErr):
+			line = parseErr.Line
+		case err != nil:
+			return fmt.Errorf("cannot read %s: %w", path, err)
+		default:
+			line, _ = r.FieldPos(0)
+			p, err = csvProduct(field(record, "product_id"), field(record, "title"), field(record, "image_url"))
+		}
+		if err := fn(line, field(record, "shop"), p, err); err != nil {
+			return err
+		}
+	}
+}
+
+func csvProduct(id, title, imageURL string) (model.SuggestionProduct, error) {
+	productID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return model.SuggestionProduct{}, fmt.Errorf("invalid product_id %q", id)
+	}
+	if imageURL == "" {
+		return model.SuggestionProduct{}, fmt.Errorf("missing image_url")
+	}
+	return model.SuggestionProduct{
+		ProductID:        productID,
+		ImageURL:         imageURL,
+		LocalUnavailable: true,
+		Product: model.Product{
+			ProductID: productID,
+			Title:     title,
+			Image:     imageURL,
+		},
+	}, nil
+}
+
+func csvShopGroup(shop string) model.ShopGroup {
+	id := CSVShopID(shop)
+	g := model.ShopGroup{ShopID: id, Shop: model.Shop{ShopID: id}}
+	if id < 0 {
+		g.Shop.MyshopifyDomain = shop
+	}
+	return g
+}
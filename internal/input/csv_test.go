@@ -0,0 +1,158 @@
+package input
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+func TestCSVShopID(t *testing.T) {
+	tests := []struct {
+		shop    string
+		want    int64
+		derived bool // want any negative ID, distinct from the other derived ones
+	}{
+		{shop: "", want: 0},
+		{shop: "42", want: 42},
+		{shop: "a.myshopify.com", derived: true},
+		{shop: "b.myshopify.com", derived: true},
+	}
+	seen := make(map[int64]string)
+	for _, tt := range tests {
+		got := CSVShopID(tt.shop)
+		switch {
+		case !tt.derived && got != tt.want:
+			t.Errorf("CSVShopID(%q) = %d, want %d", tt.shop, got, tt.want)
+		case tt.derived && got >= 0:
+			t.Errorf("CSVShopID(%q) = %d, want a negative ID", tt.shop, got)
+		case tt.derived:
+			if other, ok := seen[got]; ok {
+				t.Errorf("CSVShopID(%q) = CSVShopID(%q) = %d", tt.shop, other, got)
+			}
+			seen[got] = tt.shop
+		}
+	}
+	if CSVShopID("A.Myshopify.com") != CSVShopID("a.myshopify.com") {
+		t.Error("derived shop IDs depend on the case of the domain")
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	type row struct {
+		shopID    int64
+		productID int64
+		title     string
+	}
+	tests := []struct {
+		name    string
+		data    string
+		want    []row   // products in order
+		shops   []int64 // shop groups in order
+		wantErr string  // substring of the error, empty when it loads
+	}{
+		{
+			name:  "grouped by shop",
+			data:  "product_id,title,image_url,shop\n1,Shirt,https://img/1.jpg,7\n2,Mug,https://img/2.jpg,8\n3,\"Hat, red\",https://img/3.jpg,7\n",
+			want:  []row{{7, 1, "Shirt"}, {7, 3, "Hat, red"}, {8, 2, "Mug"}},
+			shops: []int64{7, 8},
+		},
+		{
+			name:  "columns in any order and case",
+			data:  "Image_URL, Product_ID ,TITLE\nhttps://img/1.jpg,1,Shirt\n",
+			want:  []row{{0, 1, "Shirt"}},
+			shops: []int64{0},
+		},
+		{
+			name:  "domain shops",
+			data:  "product_id,title,image_url,shop\n1,Shirt,https://img/1.jpg,a.myshopify.com\n2,Mug,https://img/2.jpg,A.myshopify.com\n",
+			want:  []row{{CSVShopID("a.myshopify.com"), 1, "Shirt"}, {CSVShopID("a.myshopify.com"), 2, "Mug"}},
+			shops: []int64{CSVShopID("a.myshopify.com")},
+		},
+		{
+			name:  "header only",
+			data:  "product_id,title,image_url\n",
+			shops: nil,
+		},
+		{
+			name:    "missing column",
+			data:    "product_id,title\n1,Shirt\n",
+			wantErr: `missing column "image_url"`,
+		},
+		{
+			name:    "bad product id",
+			data:    "product_id,title,image_url\n1,Shirt,https://img/1.jpg\nx,Mug,https://img/2.jpg\n",
+			wantErr: `batch.csv:3: invalid product_id "x"`,
+		},
+		{
+			name:    "missing image",
+			data:    "product_id,title,image_url\n1,Shirt,\n",
+			wantErr: "batch.csv:2: missing image_url",
+		},
+		{
+			name:    "unterminated quote",
+			data:    "product_id,title,image_url\n1,\"Shirt,https://img/1.jpg\n",
+			wantErr: "batch.csv:2:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := LoadCSV(writeTemp(t, "batch.csv", tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadCSV error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var shops []int64
+			var got []row
+			for _, g := range groups {
+				shops = append(shops, g.ShopID)
+				if g.ProductCount != len(g.SuggestionProducts) {
+					t.Errorf("shop %d counts %d products, holds %d", g.ShopID, g.ProductCount, len(g.SuggestionProducts))
+				}
+				if g.ShopID < 0 && g.Shop.MyshopifyDomain == "" {
+					t.Errorf("shop %d lost its domain", g.ShopID)
+				}
+				for _, p := range g.SuggestionProducts {
+					got = append(got, row{p.ShopID, p.ProductID, p.Product.Title})
+					if !p.LocalUnavailable || p.ImageURL == "" || p.Product.ShopID != p.ShopID {
+						t.Errorf("product %+v", p)
+					}
+				}
+			}
+			if !reflect.DeepEqual(shops, tt.shops) {
+				t.Errorf("shops %v, want %v", shops, tt.shops)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("products %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamCSV(t *testing.T) {
+	path := writeTemp(t, "batch.csv", "product_id,title,image_url,shop\n1,Shirt,https://img/1.jpg,7\nx,Mug,https://img/2.jpg,8\n3,Hat,https://img/3.jpg\n")
+	type call struct {
+		line      int
+		shop      string
+		productID int64
+		failed    bool
+	}
+	var got []call
+	err := StreamCSV(path, func(line int, shop string, p model.SuggestionProduct, err error) error {
+		got = append(got, call{line, shop, p.ProductID, err != nil})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []call{{2, "7", 1, false}, {3, "8", 0, true}, {4, "", 3, false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("calls %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// LoadJSON reads a JSON array of shop groups. Both plain JSON and MongoDB
+// Extended JSON (canonical or relaxed, as written by mongoexport) are accepted.
+func LoadJSON(path string) ([]model.ShopGroup, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	data, err := NormalizeExtJSON(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	var groups []model.ShopGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal %s: %w", path, err)
+	}
+	return groups, nil
+}
+
+// NormalizeExtJSON rewrites MongoDB Extended JSON type wrappers into plain
+// JSON values: $oid becomes its hex string, $date an RFC 3339 string and
+// $numberLong/$numberInt/$numberDouble/$numberDecimal plain numbers.
+// Other values are left untouched.
+func NormalizeExtJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := normalizeValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func normalizeValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case []interface{}:
+		for i := range t {
+			n, err := normalizeValue(t[i])
+			if err != nil {
+				return nil, err
+			}
+			t[i] = n
+		}
+		return t, nil
+	case map[string]interface{}:
+		if len(t) == 1 {
+			for key, inner := range t {
+				if n, ok, err := unwrap(key, inner); ok || err != nil {
+					return n, err
+				}
+			}
+		}
+		for k := range t {
+			n, err := normalizeValue(t[k])
+			if err != nil {
+				return nil, err
+			}
+			t[k] = n
+		}
+		return t, nil
+	}
+	return v, nil
+}
+
+// unwrap converts a single-key Extended JSON wrapper. ok is false when key is
+// not a known wrapper.
+func unwrap(key string, inner interface{}) (v interface{}, ok bool, err error) {
+	switch key {
+	case "$oid":
+		s, isString := inner.(string)
+		if !isString {
+			return nil, true, fmt.Errorf("invalid $oid %v", inner)
+		}
+		return s, true, nil
+	case "$numberLong", "$numberInt", "$numberDouble", "$numberDecimal":
+		n, err := number(inner)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return n, true, nil
+	case "$date":
+		t, err := date(inner)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid $date: %w", err)
+		}
+		return t.UTC().Format(time.RFC3339Nano), true, nil
+	case "$timestamp":
+		if m, isMap := inner.(map[string]interface{}); isMap {
+			if secs, err := number(m["t"]); err == nil && secs != nil {
+				s, _ := secs.(json.Number).Int64()
+				return time.Unix(s, 0).UTC().Format(time.RFC3339Nano), true, nil
+			}
+		}
+		return nil, true, fmt.Errorf("invalid $timestamp %v", inner)
+	}
+	return nil, false, nil
+}
+
+// number returns a JSON number for a numeric string or number. Non-finite
+// doubles have no JSON representation and become null.
+func number(v interface{}) (interface{}, error) {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case json.Number:
+		s = t.String()
+	default:
+		return nil, fmt.Errorf("unexpected value %v", v)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, nil
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(s), nil
+	}
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64)), nil
+}
+
+// date parses the relaxed ISO-8601 form and the canonical milliseconds form
+func date(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, t)
+	case json.Number:
+		ms, err := t.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms), nil
+	case map[string]interface{}:
+		if n, ok := t["$numberLong"]; ok {
+			s, isString := n.(string)
+			if !isString {
+				return time.Time{}, fmt.Errorf("unexpected value %v", n)
+			}
+			ms, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.UnixMilli(ms), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unexpected value %v", v)
+}
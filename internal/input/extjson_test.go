@@ -0,0 +1,113 @@
+package input
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeExtJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"oid", `{"_id":{"$oid":"65a1f0c2e4b0a1b2c3d4e5f6"}}`, `{"_id":"65a1f0c2e4b0a1b2c3d4e5f6"}`},
+		{"numberLong", `{"n":{"$numberLong":"42"}}`, `{"n":42}`},
+		{"numberLong beyond float64", `{"n":{"$numberLong":"9007199254740993"}}`, `{"n":9007199254740993}`},
+		{"negative numberLong", `{"n":{"$numberLong":"-7"}}`, `{"n":-7}`},
+		{"numberInt", `{"n":{"$numberInt":"7"}}`, `{"n":7}`},
+		{"numberDouble", `{"n":{"$numberDouble":"1.5"}}`, `{"n":1.5}`},
+		{"numberDouble NaN", `{"n":{"$numberDouble":"NaN"}}`, `{"n":null}`},
+		{"numberDouble Infinity", `{"n":{"$numberDouble":"-Infinity"}}`, `{"n":null}`},
+		{"numberDecimal", `{"n":{"$numberDecimal":"12.50"}}`, `{"n":12.5}`},
+		{"relaxed number", `{"n":{"$numberLong":42}}`, `{"n":42}`},
+		{"relaxed date", `{"d":{"$date":"2025-01-02T03:04:05.678Z"}}`, `{"d":"2025-01-02T03:04:05.678Z"}`},
+		{"relaxed date with offset", `{"d":{"$date":"2025-01-02T05:04:05+02:00"}}`, `{"d":"2025-01-02T03:04:05Z"}`},
+		{"canonical date", `{"d":{"$date":{"$numberLong":"1735787045678"}}}`, `{"d":"2025-01-02T03:04:05.678Z"}`},
+		{"legacy date", `{"d":{"$date":1735787045678}}`, `{"d":"2025-01-02T03:04:05.678Z"}`},
+		{"timestamp", `{"ts":{"$timestamp":{"t":1735787045,"i":1}}}`, `{"ts":"2025-01-02T03:04:05Z"}`},
+		{
+			"nested",
+			`[{"shop_id":{"$numberLong":"1"},"suggestion_products":[{"_id":{"$oid":"65a1f0c2e4b0a1b2c3d4e5f6"},"created_at":{"$date":"2025-01-02T03:04:05Z"},"products":[{"totalreview":{"$numberLong":"12"}}]}]}]`,
+			`[{"shop_id":1,"suggestion_products":[{"_id":"65a1f0c2e4b0a1b2c3d4e5f6","created_at":"2025-01-02T03:04:05Z","products":[{"totalreview":12}]}]}]`,
+		},
+		{"plain JSON", `{"a":[1,"x",{"b":null}],"c":true,"d":1.25}`, `{"a":[1,"x",{"b":null}],"c":true,"d":1.25}`},
+		{"wrapper key among others", `{"$oid":"abc","other":1}`, `{"$oid":"abc","other":1}`},
+		{"unknown wrapper", `{"re":{"$regularExpression":{"pattern":"a","options":""}}}`, `{"re":{"$regularExpression":{"options":"","pattern":"a"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeExtJSON([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NormalizeExtJSON(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeExtJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"truncated", `{"a":`},
+		{"not JSON", `shop_id,product_id`},
+		{"oid not a string", `{"_id":{"$oid":12}}`},
+		{"numberLong not a number", `{"n":{"$numberLong":"abc"}}`},
+		{"numberLong bool", `{"n":{"$numberLong":true}}`},
+		{"date text", `{"d":{"$date":"yesterday"}}`},
+		{"canonical date not a string", `{"d":{"$date":{"$numberLong":5}}}`},
+		{"date bool", `{"d":{"$date":false}}`},
+		{"timestamp not an object", `{"ts":{"$timestamp":"x"}}`},
+		{"nested in array", `[{"x":[{"_id":{"$oid":1}}]}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := NormalizeExtJSON([]byte(tt.in)); err == nil {
+				t.Errorf("NormalizeExtJSON(%s) = %s, want an error", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestLoadJSONExtended(t *testing.T) {
+	path := writeTemp(t, "export.json", `[{
+		"shop_id": {"$numberLong": "5"},
+		"shop": {"shop_id": 5, "myshopify_domain": "five.myshopify.com"},
+		"product_count": {"$numberInt": "1"},
+		"suggestion_products": [{
+			"_id": {"$oid": "65a1f0c2e4b0a1b2c3d4e5f6"},
+			"shop_id": {"$numberLong": "5"},
+			"product_id": {"$numberLong": "9007199254740993"},
+			"created_at": {"$date": {"$numberLong": "1735787045678"}},
+			"products": [{"productid": "1", "totalreview": {"$numberLong": "12"}, "avgstar": {"$numberDouble": "4.5"}}]
+		}]
+	}]`)
+	groups, err := LoadJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0].SuggestionProducts) != 1 {
+		t.Fatalf("got %+v, want one shop with one product", groups)
+	}
+	g := groups[0]
+	p := g.SuggestionProducts[0]
+	if g.ShopID != 5 || g.ProductCount != 1 || g.Shop.MyshopifyDomain != "five.myshopify.com" {
+		t.Errorf("shop = %d/%d/%q", g.ShopID, g.ProductCount, g.Shop.MyshopifyDomain)
+	}
+	if p.ID.Hex() != "65a1f0c2e4b0a1b2c3d4e5f6" {
+		t.Errorf("_id = %s", p.ID.Hex())
+	}
+	if p.ProductID != 9007199254740993 {
+		t.Errorf("product_id = %d", p.ProductID)
+	}
+	if want := time.UnixMilli(1735787045678).UTC(); !p.CreatedAt.Equal(want) {
+		t.Errorf("created_at = %s, want %s", p.CreatedAt, want)
+	}
+	if item := p.Products[0]; item.TotalReview != 12 || item.AvgStar != 4.5 {
+		t.Errorf("products[0] = %+v", item)
+	}
+}
@@ -0,0 +1,105 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// IsNDJSON reports whether a path names a newline-delimited JSON file
+func IsNDJSON(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return true
+	}
+	return false
+}
+
+// StreamNDJSON reads one JSON document per line and calls fn for every
+// suggestion product, without loading the whole file. A line holds either a
+// shop group, like an element of the JSON array input, or a single suggestion
+// document as written by mongoexport. Extended JSON is accepted.
+func StreamNDJSON(path string, fn func(shop model.Shop, p model.SuggestionProduct) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	for line := 1; ; line++ {
+		data, readErr := r.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("cannot read %s: %w", path, readErr)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := decodeLine(data, fn); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+		}
+		if readErr != nil { // EOF
+			return nil
+		}
+	}
+}
+
+func decodeLine(data []byte, fn func(shop model.Shop, p model.SuggestionProduct) error) error {
+	data, err := NormalizeExtJSON(data)
+	if err != nil {
+		return err
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+
+	if _, isGroup := probe["suggestion_products"]; isGroup {
+		var g model.ShopGroup
+		if err := json.Unmarshal(data, &g); err != nil {
+			return err
+		}
+		shop := g.Shop
+		if shop.ShopID == 0 {
+			shop.ShopID = g.ShopID
+		}
+		for _, p := range g.SuggestionProducts {
+			if err := fn(shop, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var p model.SuggestionProduct
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	return fn(model.Shop{ShopID: p.ShopID}, p)
+}
+
+// LoadNDJSON reads a whole NDJSON input, grouping products by shop in order
+// of first appearance
+func LoadNDJSON(path string) ([]model.ShopGroup, error) {
+	var groups []model.ShopGroup
+	index := make(map[int64]int)
+	err := StreamNDJSON(path, func(shop model.Shop, p model.SuggestionProduct) error {
+		i, ok := index[shop.ShopID]
+		if !ok {
+			i = len(groups)
+			index[shop.ShopID] = i
+			groups = append(groups, model.ShopGroup{ShopID: shop.ShopID, Shop: shop})
+		}
+		groups[i].SuggestionProducts = append(groups[i].SuggestionProducts, p)
+		groups[i].ProductCount++
+		return nil
+	})
+	return groups, err
+}
@@ -0,0 +1,166 @@
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// Strata a sample can be drawn over
+const (
+	SampleByShop    = "shop"     // Shop.ShopID
+	SampleByPlan    = "plan"     // Shop.PlanDisplayName
+	SampleByAppPlan = "app_plan" // Shop.AppPlan
+	SampleByType    = "type"     // Product.Type
+)
+
+// SampledProduct identifies a drawn product
+type SampledProduct struct {
+	SuggestionID string `json:"suggestion_id,omitempty"`
+	ShopID       int64  `json:"shop_id"`
+	ProductID    int64  `json:"product_id"`
+}
+
+// Sample describes how a sample was drawn and what it contains, so that the
+// same sample can be re-drawn from the same input
+type Sample struct {
+	Size     int              `json:"size"` // products per stratum
+	By       string           `json:"by"`
+	Seed     uint64           `json:"seed"`
+	Products []SampledProduct `json:"products"`
+}
+
+// Draw picks up to s.Size products per stratum with a generator seeded from
+// s.Seed and the stratum key, so adding a stratum does not change the others.
+// Selected products keep their input order. Products are recorded in s.
+func Draw(groups []model.ShopGroup, s *Sample) ([]model.ShopGroup, error) {
+	if s.Size <= 0 {
+		return nil, fmt.Errorf("sample size must be positive, got %d", s.Size)
+	}
+
+	type ref struct{ group, product int }
+	strata := make(map[string][]ref)
+	for gi, g := range groups {
+		for pi, p := range g.SuggestionProducts {
+			key, err := stratum(s.By, g, p)
+			if err != nil {
+				return nil, err
+			}
+			strata[key] = append(strata[key], ref{gi, pi})
+		}
+	}
+
+	keys := make([]string, 0, len(strata))
+	for k := range strata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	chosen := make(map[ref]bool)
+	for _, key := range keys {
+		refs := strata[key]
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		rng := rand.New(rand.NewPCG(s.Seed, h.Sum64()))
+		rng.Shuffle(len(refs), func(i, j int) { refs[i], refs[j] = refs[j], refs[i] })
+		for _, r := range refs[:min(s.Size, len(refs))] {
+			chosen[r] = true
+		}
+	}
+
+	s.Products = nil
+	out := filterGroups(groups, func(gi, pi int) bool { return chosen[ref{gi, pi}] })
+	for _, g := range out {
+		for _, p := range g.SuggestionProducts {
+			s.Products = append(s.Products, sampledProduct(p))
+		}
+	}
+	return out, nil
+}
+
+// Replay keeps exactly the products recorded in a previously drawn sample
+func Replay(groups []model.ShopGroup, s Sample) []model.ShopGroup {
+	want := make(map[SampledProduct]bool, len(s.Products))
+	for _, p := range s.Products {
+		want[p] = true
+	}
+	return filterGroups(groups, func(gi, pi int) bool {
+		return want[sampledProduct(groups[gi].SuggestionProducts[pi])]
+	})
+}
+
+// LoadSample reads a sample definition: a file of its own, or the sample
+// recorded in the envelope of a report (JSON, or the first NDJSON line)
+func LoadSample(path string) (Sample, error) {
+	var s Sample
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("failed to read sample %s: %w", path, err)
+	}
+	if IsNDJSON(path) {
+		data, _, _ = bytes.Cut(data, []byte{'\n'})
+	}
+
+	var envelope struct {
+		Sample *Sample `json:"sample"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return s, fmt.Errorf("failed to parse sample %s: %w", path, err)
+	}
+	if envelope.Sample != nil {
+		return *envelope.Sample, nil
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse sample %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func stratum(by string, g model.ShopGroup, p model.SuggestionProduct) (string, error) {
+	switch by {
+	case SampleByShop, "":
+		return strconv.FormatInt(g.ShopID, 10), nil
+	case SampleByPlan:
+		return g.Shop.PlanDisplayName, nil
+	case SampleByAppPlan:
+		return g.Shop.AppPlan, nil
+	case SampleByType:
+		return p.Product.Type, nil
+	}
+	return "", fmt.Errorf("unknown sample stratum %q, expected shop, plan, app_plan or type", by)
+}
+
+func sampledProduct(p model.SuggestionProduct) SampledProduct {
+	sp := SampledProduct{ShopID: p.ShopID, ProductID: p.ProductID}
+	if !p.ID.IsZero() {
+		sp.SuggestionID = p.ID.Hex()
+	}
+	return sp
+}
+
+// filterGroups keeps the products for which keep returns true, dropping
+// shops left empty
+func filterGroups(groups []model.ShopGroup, keep func(group, product int) bool) []model.ShopGroup {
+	var out []model.ShopGroup
+	for gi, g := range groups {
+		group := g
+		group.SuggestionProducts = nil
+		for pi, p := range g.SuggestionProducts {
+			if keep(gi, pi) {
+				group.SuggestionProducts = append(group.SuggestionProducts, p)
+			}
+		}
+		if len(group.SuggestionProducts) > 0 {
+			group.ProductCount = len(group.SuggestionProducts)
+			out = append(out, group)
+		}
+	}
+	return out
+}
@@ -0,0 +1,170 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// sampleGroups builds shops with the given number of products each. Shop i
+// has ID i+1, plans alternate Basic/Plus and products alternate shirt/mug.
+func sampleGroups(sizes ...int) []model.ShopGroup {
+	var groups []model.ShopGroup
+	for i, n := range sizes {
+		shopID := int64(i + 1)
+		g := model.ShopGroup{ShopID: shopID, Shop: model.Shop{ShopID: shopID, PlanDisplayName: []string{"Basic", "Plus"}[i%2]}}
+		for j := range n {
+			g.SuggestionProducts = append(g.SuggestionProducts, model.SuggestionProduct{
+				ShopID:    shopID,
+				ProductID: shopID*100 + int64(j),
+				Product:   model.Product{Type: []string{"shirt", "mug"}[j%2]},
+			})
+		}
+		g.ProductCount = n
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int
+		by    string
+		size  int
+		want  map[string]int // products drawn per stratum
+	}{
+		{"per shop", []int{10, 3, 1}, SampleByShop, 2, map[string]int{"1": 2, "2": 2, "3": 1}},
+		{"default stratum is shop", []int{10, 3}, "", 4, map[string]int{"1": 4, "2": 3}},
+		{"capped by stratum size", []int{2, 2}, SampleByShop, 5, map[string]int{"1": 2, "2": 2}},
+		{"per plan", []int{10, 3, 1}, SampleByPlan, 3, map[string]int{"Basic": 3, "Plus": 3}},
+		{"per type", []int{5, 4}, SampleByType, 2, map[string]int{"shirt": 2, "mug": 2}},
+		{"one product", []int{10, 3, 1}, SampleByShop, 1, map[string]int{"1": 1, "2": 1, "3": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := sampleGroups(tt.sizes...)
+			s := Sample{Size: tt.size, By: tt.by, Seed: 42}
+			out, err := Draw(groups, &s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]int)
+			total := 0
+			for _, g := range out {
+				if g.ProductCount != len(g.SuggestionProducts) {
+					t.Errorf("shop %d: product_count %d, %d products", g.ShopID, g.ProductCount, len(g.SuggestionProducts))
+				}
+				last := int64(-1)
+				for _, p := range g.SuggestionProducts {
+					key, _ := stratum(tt.by, g, p)
+					got[key]++
+					total++
+					if p.ProductID <= last {
+						t.Errorf("shop %d: product %d drawn after %d, input order lost", g.ShopID, p.ProductID, last)
+					}
+					last = p.ProductID
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("per stratum %v, want %v", got, tt.want)
+			}
+			if len(s.Products) != total {
+				t.Errorf("recorded %d products, drew %d", len(s.Products), total)
+			}
+
+			again := Sample{Size: tt.size, By: tt.by, Seed: 42}
+			if _, err := Draw(sampleGroups(tt.sizes...), &again); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again.Products, s.Products) {
+				t.Errorf("same seed drew %v, then %v", s.Products, again.Products)
+			}
+
+			if replayed := Replay(groups, s); !reflect.DeepEqual(replayed, out) {
+				t.Errorf("Replay = %v, want %v", replayed, out)
+			}
+		})
+	}
+}
+
+func TestDrawSeed(t *testing.T) {
+	draw := func(seed uint64, sizes ...int) map[int64][]SampledProduct {
+		s := Sample{Size: 3, By: SampleByShop, Seed: seed}
+		if _, err := Draw(sampleGroups(sizes...), &s); err != nil {
+			t.Fatal(err)
+		}
+		byShop := make(map[int64][]SampledProduct)
+		for _, p := range s.Products {
+			byShop[p.ShopID] = append(byShop[p.ShopID], p)
+		}
+		return byShop
+	}
+
+	a, b := draw(1, 50, 50), draw(2, 50, 50)
+	if reflect.DeepEqual(a, b) {
+		t.Errorf("seeds 1 and 2 drew the same sample %v", a)
+	}
+
+	// a new stratum leaves the draw of the others alone
+	grown := draw(1, 50, 50, 7)
+	for shopID, products := range a {
+		if !reflect.DeepEqual(grown[shopID], products) {
+			t.Errorf("shop %d: drew %v, then %v once a shop was added", shopID, products, grown[shopID])
+		}
+	}
+}
+
+func TestDrawErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample Sample
+	}{
+		{"zero size", Sample{Size: 0, By: SampleByShop}},
+		{"negative size", Sample{Size: -1, By: SampleByShop}},
+		{"unknown stratum", Sample{Size: 1, By: "country"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Draw(sampleGroups(3), &tt.sample); err == nil {
+				t.Errorf("Draw(%+v) succeeded", tt.sample)
+			}
+		})
+	}
+}
+
+func TestLoadSample(t *testing.T) {
+	want := Sample{Size: 2, By: SampleByPlan, Seed: 7, Products: []SampledProduct{{ShopID: 1, ProductID: 100}}}
+	tests := []struct {
+		name, file, data string
+	}{
+		{"sample file", "sample.json", `{"size":2,"by":"plan","seed":7,"products":[{"shop_id":1,"product_id":100}]}`},
+		{"report envelope", "report.json", `{"run_id":"x","sample":{"size":2,"by":"plan","seed":7,"products":[{"shop_id":1,"product_id":100}]},"reports":[]}`},
+		{"ndjson envelope", "report.ndjson", `{"run_id":"x","sample":{"size":2,"by":"plan","seed":7,"products":[{"shop_id":1,"product_id":100}]}}` + "\n" + `{"ProductID":100}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTemp(t, tt.file, tt.data)
+			got, err := LoadSample(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadSample = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func writeTemp(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
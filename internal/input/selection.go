@@ -0,0 +1,114 @@
+package input
+
+import (
+	"strings"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// Selection narrows the suggestion products of an input down to a focused
+// run. Empty fields do not filter; a shop matches on ShopIDs or Domains.
+type Selection struct {
+	ShopIDs     []int64
+	Domains     []string // myshopify domains
+	JobIDs      []string
+	Statuses    []string
+	CreatedFrom time.Time // inclusive
+	CreatedTo   time.Time // exclusive
+	Limit       int       // maximum number of products, 0 for all
+}
+
+// IsZero reports whether the selection keeps every product
+func (s Selection) IsZero() bool {
+	return len(s.ShopIDs) == 0 && len(s.Domains) == 0 && len(s.JobIDs) == 0 && len(s.Statuses) == 0 &&
+		s.CreatedFrom.IsZero() && s.CreatedTo.IsZero() && s.Limit == 0
+}
+
+// MatchShop reports whether the shop passes the shop filter
+func (s Selection) MatchShop(shop model.Shop) bool {
+	if len(s.ShopIDs) == 0 && len(s.Domains) == 0 {
+		return true
+	}
+	for _, id := range s.ShopIDs {
+		if shop.ShopID == id {
+			return true
+		}
+	}
+	for _, d := range s.Domains {
+		if strings.EqualFold(shop.MyshopifyDomain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchProduct reports whether a product passes the job, status and
+// created-at filters
+func (s Selection) MatchProduct(p model.SuggestionProduct) bool {
+	if len(s.JobIDs) > 0 && !containsFold(s.JobIDs, p.JobID) {
+		return false
+	}
+	if len(s.Statuses) > 0 && !containsFold(s.Statuses, p.Status) {
+		return false
+	}
+	if !s.CreatedFrom.IsZero() && p.CreatedAt.Before(s.CreatedFrom) {
+		return false
+	}
+	if !s.CreatedTo.IsZero() && !p.CreatedAt.Before(s.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// Select returns the shops and products matching the selection, in input
+// order, up to Limit products. Shops left without products are dropped and
+// ProductCount reflects the selected products.
+func Select(groups []model.ShopGroup, s Selection) []model.ShopGroup {
+	if s.IsZero() {
+		return groups
+	}
+
+	var out []model.ShopGroup
+	selected := 0
+	for _, g := range groups {
+		if s.Limit > 0 && selected >= s.Limit {
+			break
+		}
+		// exports sometimes only fill the group's shop_id
+		shop := g.Shop
+		if shop.ShopID == 0 {
+			shop.ShopID = g.ShopID
+		}
+		if !s.MatchShop(shop) {
+			continue
+		}
+
+		group := g
+		group.SuggestionProducts = nil
+		for _, p := range g.SuggestionProducts {
+			if s.Limit > 0 && selected >= s.Limit {
+				break
+			}
+			if !s.MatchProduct(p) {
+				continue
+			}
+			group.SuggestionProducts = append(group.SuggestionProducts, p)
+			selected++
+		}
+		if len(group.SuggestionProducts) > 0 {
+			group.ProductCount = len(group.SuggestionProducts)
+			out = append(out, group)
+		}
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
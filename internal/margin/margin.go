@@ -0,0 +1,97 @@
+package margin
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+// Gross returns the gross margin of selling at sellingPrice an item bought at
+// cost plus shipping, as a fraction of the selling price
+func Gross(sellingPrice, cost, shipping float64) float64 {
+	return (sellingPrice - cost - shipping) / sellingPrice
+}
+
+// LoadPriceFile reads Shopify selling prices from a "product_id,price" CSV
+// file. A header row is skipped when present.
+func LoadPriceFile(path string) (map[int64]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open price file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	prices := make(map[int64]float64)
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read price file %s: %w", path, err)
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected product_id,price", path, line)
+		}
+		productID, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			if line == 1 { // header
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid product id %q", path, line, record[0])
+		}
+		price := candidate.ParsePrice(record[1])
+		if price <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid price %q", path, line, record[1])
+		}
+		prices[productID] = price
+	}
+	return prices, nil
+}
+
+// Apply sets the selling price of a report and the margin of every candidate
+// with a known price. Candidates without a price keep a nil margin.
+func Apply(r *report.Report, sellingPrice, shipping float64) {
+	r.SellingPrice = sellingPrice
+	r.BestMargin = nil
+	if sellingPrice <= 0 {
+		return
+	}
+
+	compute := func(c candidate.Candidate) *float64 {
+		if c.Price <= 0 {
+			return nil
+		}
+		m := Gross(sellingPrice, c.Price, shipping)
+		if r.BestMargin == nil || m > *r.BestMargin {
+			best := m
+			r.BestMargin = &best
+		}
+		return &m
+	}
+
+	for _, items := range [][]model.ProductItem{r.LocalRapidAPITop, r.LocalRapidAPIOrigin} {
+		for i := range items {
+			items[i].Margin = compute(candidate.FromLocal(items[i]))
+		}
+	}
+	for _, items := range [][]model.AliHunterProduct{r.AliHunterTop, r.AliHunterOrigin} {
+		for i := range items {
+			items[i].Margin = compute(candidate.FromAliHunter(items[i]))
+		}
+	}
+	for _, items := range [][]model.AliExpressProduct{r.AliExpressTop, r.AliExpressOrigin} {
+		for i := range items {
+			items[i].Margin = compute(candidate.FromAliExpress(items[i]))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package margin
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/report"
+)
+
+func TestGross(t *testing.T) {
+	tests := []struct {
+		selling, cost, shipping float64
+		want                    float64
+	}{
+		{20, 5, 0, 0.75},
+		{20, 5, 5, 0.5},
+		{10, 12, 0, -0.2},
+		{10, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Gross(tt.selling, tt.cost, tt.shipping); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Gross(%g, %g, %g) = %g, want %g", tt.selling, tt.cost, tt.shipping, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPriceFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[int64]float64
+		wantErr bool
+	}{
+		{"header", "product_id,price\n1,19.99\n2,$5\n", map[int64]float64{1: 19.99, 2: 5}, false},
+		{"no header", "1,19.99\n", map[int64]float64{1: 19.99}, false},
+		{"extra columns", "1, 7.50 USD,note\n", map[int64]float64{1: 7.5}, false},
+		{"empty", "", map[int64]float64{}, false},
+		{"bad product id", "product_id,price\nabc,1\n", nil, true},
+		{"bad price", "1,free\n", nil, true},
+		{"zero price", "1,0\n", nil, true},
+		{"one column", "1\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prices.csv")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadPriceFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadPriceFile = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadPriceFile = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	r := report.Report{
+		LocalRapidAPIOrigin: []model.ProductItem{{ProductID: "l", TargetSalePrice: "$8"}},
+		AliHunterTop:        []model.AliHunterProduct{{ProductID: "h", TargetSalePrice: "500"}}, // cents
+		AliExpressTop:       []model.AliExpressProduct{{ProductID: "x", SalePrice: 0}},         // no price
+	}
+	Apply(&r, 20, 2)
+
+	if r.SellingPrice != 20 {
+		t.Errorf("selling price %g", r.SellingPrice)
+	}
+	margins := []struct {
+		name string
+		got  *float64
+		want float64
+	}{
+		{"local", r.LocalRapidAPIOrigin[0].Margin, 0.5},
+		{"alihunter", r.AliHunterTop[0].Margin, 0.65},
+	}
+	for _, m := range margins {
+		if m.got == nil || math.Abs(*m.got-m.want) > 1e-9 {
+			t.Errorf("%s margin %v, want %g", m.name, m.got, m.want)
+		}
+	}
+	if r.AliExpressTop[0].Margin != nil {
+		t.Errorf("candidate without a price got margin %g", *r.AliExpressTop[0].Margin)
+	}
+	if r.BestMargin == nil || math.Abs(*r.BestMargin-0.65) > 1e-9 {
+		t.Errorf("best margin %v, want 0.65", r.BestMargin)
+	}
+
+	// without a selling price there is no best margin
+	Apply(&r, 0, 2)
+	if r.BestMargin != nil {
+		t.Errorf("best margin %g without a selling price", *r.BestMargin)
+	}
+}
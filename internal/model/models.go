@@ -30,17 +30,20 @@ type ResultListSearchByImage struct {
 }
 
 type AliExpressProduct struct {
-	ProductID     string  `json:"product_id"`
-	URL           string  `json:"url"`
-	Title         string  `json:"title"`
-	ImageURL      string  `json:"image_url"`
-	AvgRatingStar float64 `json:"avg_rating_star"`
-	Volume        int64   `json:"volume"`
-	SalePrice     float64 `json:"sale_price"`     // Current sale price
-	OriginalPrice float64 `json:"original_price"` // Original price
-	TotalReview   string  `json:"total_review"`
-	Matching      bool    `json:"matching"` // Whether the product is matching
-	Similar       bool    `json:"similar"`  // Whether the product is similar
+	ProductID     string   `json:"product_id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ImageURL      string   `json:"image_url"`
+	AvgRatingStar float64  `json:"avg_rating_star"`
+	HasRating     bool     `json:"has_rating,omitempty"` // averageStarRate was present, even if not a number
+	Volume        int64    `json:"volume"`
+	SalePrice     float64  `json:"sale_price"`     // Current sale price
+	OriginalPrice float64  `json:"original_price"` // Original price
+	TotalReview   string   `json:"total_review"`
+	RankScore     float64  `json:"rank_score,omitempty"` // Score assigned by the ranking stage
+	Margin        *float64 `json:"margin,omitempty"`     // Gross margin against the Shopify selling price
+	Matching      bool     `json:"matching"`             // Whether the product is matching
+	Similar       bool     `json:"similar"`              // Whether the product is similar
 }
 
 type AliHunterSearchByImageResponse struct {
@@ -53,20 +56,22 @@ type AliHunterSearchByImageResponse struct {
 }
 
 type AliHunterProduct struct {
-	ProductID               string `json:"product_id"`
-	EvaluateRate            string `json:"evaluate_rate"`
-	ProductTitle            string `json:"product_title"`
-	ProductMainImageURL     string `json:"product_main_image_url"`
-	ProductDetailURL        string `json:"product_detail_url"`
-	TargetSalePrice         string `json:"target_sale_price"`
-	TargetOriginalPrice     string `json:"target_original_price"`
-	LatestVolume            string `json:"latest_volume"`
-	SimilarityScore         string `json:"similarity_score"`
-	ShipFrom                string `json:"ship_from"`
-	TargetSalePriceCurrency string `json:"target_sale_price_currency"`
-	TotalReview             string `json:"total_review"`
-	Matching                bool   `json:"matching"`
-	Similar                 bool   `json:"similar"`
+	ProductID               string   `json:"product_id"`
+	EvaluateRate            string   `json:"evaluate_rate"`
+	ProductTitle            string   `json:"product_title"`
+	ProductMainImageURL     string   `json:"product_main_image_url"`
+	ProductDetailURL        string   `json:"product_detail_url"`
+	TargetSalePrice         string   `json:"target_sale_price"`
+	TargetOriginalPrice     string   `json:"target_original_price"`
+	LatestVolume            string   `json:"latest_volume"`
+	SimilarityScore         string   `json:"similarity_score"`
+	ShipFrom                string   `json:"ship_from"`
+	TargetSalePriceCurrency string   `json:"target_sale_price_currency"`
+	TotalReview             string   `json:"total_review"`
+	RankScore               float64  `json:"rank_score,omitempty"`
+	Margin                  *float64 `json:"margin,omitempty"`
+	Matching                bool     `json:"matching"`
+	Similar                 bool     `json:"similar"`
 }
 
 type ShopGroup struct {
@@ -96,22 +101,27 @@ type SuggestionProduct struct {
 	Products   []ProductItem      `bson:"products" json:"products"`
 	Product    Product            `bson:"product" json:"product"`
 	ProductURL string             `bson:"producturl" json:"producturl"`
+	// Set for inputs without production results (e.g. CSV batches)
+	LocalUnavailable bool `bson:"-" json:"local_unavailable,omitempty"`
 }
 
 type ProductItem struct {
-	Type                string      `bson:"type" json:"type"`
-	Platform            string      `bson:"platform" json:"platform"`
-	ProductID           string      `bson:"productid" json:"productid"`
-	ProductURL          string      `bson:"producturl" json:"producturl"`
-	ProductMainImageURL string      `bson:"productmainimageurl" json:"productmainimageurl"`
-	ProductTitle        string      `bson:"producttitle" json:"producttitle"`
-	TargetSalePrice     string      `bson:"targetsaleprice" json:"targetsaleprice"`
-	TargetOriginalPrice string      `bson:"targetoriginalprice" json:"targetoriginalprice"`
-	AvgStar             float64     `bson:"avgstar" json:"avgstar"`
-	Sale                int         `bson:"sale" json:"sale"`
-	TotalReview         interface{} `bson:"totalreview" json:"totalreview"` // Can be int or object like {"$numberLong": "..."}
-	Matching            bool        `bson:"matching" json:"matching"`
-	Similar             bool        `bson:"similar" json:"similar"`
+	Type                string   `bson:"type" json:"type"`
+	Platform            string   `bson:"platform" json:"platform"`
+	ProductID           string   `bson:"productid" json:"productid"`
+	ProductURL          string   `bson:"producturl" json:"producturl"`
+	ProductMainImageURL string   `bson:"productmainimageurl" json:"productmainimageurl"`
+	ProductTitle        string   `bson:"producttitle" json:"producttitle"`
+	TargetSalePrice     string   `bson:"targetsaleprice" json:"targetsaleprice"`
+	TargetOriginalPrice string   `bson:"targetoriginalprice" json:"targetoriginalprice"`
+	AvgStar             float64  `bson:"avgstar" json:"avgstar"`
+	Sale                int      `bson:"sale" json:"sale"`
+	TotalReview         int64    `bson:"totalreview" json:"totalreview"` // Exports may wrap it as {"$numberLong": "..."}, see input.LoadJSON
+	RankScore           float64  `bson:"-" json:"rank_score,omitempty"`
+	Margin              *float64 `bson:"-" json:"margin,omitempty"`
+	Matching            bool     `bson:"matching" json:"matching"`
+	Similar             bool     `bson:"similar" json:"similar"`
+	Reviewed            bool     `bson:"-" json:"reviewed,omitempty"` // set by the HTML report export once a reviewer could label it
 }
 
 type Product struct {
@@ -123,4 +133,5 @@ type Product struct {
 	Handle       string `bson:"handle" json:"handle"`
 	ProductURL   string `bson:"product_url" json:"product_url"`
 	TotalReviews int64  `bson:"total_reviews" json:"total_reviews"`
+	Price        string `bson:"price" json:"price,omitempty"` // Shopify selling price
 }
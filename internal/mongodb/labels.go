@@ -0,0 +1,184 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// LabelUpdate is a reviewed label for one entry of a suggestion's products array
+type LabelUpdate struct {
+	SuggestionID primitive.ObjectID
+	ProductID    string // productid of the products array entry
+	Matching     bool
+	Similar      bool
+}
+
+// Labels is a Matching/Similar pair
+type Labels struct {
+	Matching bool `json:"matching"`
+	Similar  bool `json:"similar"`
+}
+
+// LabelChange describes a products array entry whose labels differ from the
+// reviewed ones
+type LabelChange struct {
+	Time         time.Time `json:"time"`
+	DryRun       bool      `json:"dry_run"`
+	SuggestionID string    `json:"suggestion_id"`
+	ProductID    string    `json:"productid"`
+	Old          Labels    `json:"old"`
+	New          Labels    `json:"new"`
+	Skipped      string    `json:"skipped,omitempty"` // reason the entry could not be updated
+	Error        string    `json:"error,omitempty"`   // the update failed, the entry keeps its old labels
+}
+
+// Written reports whether the change was applied, or would be in a dry run
+func (c LabelChange) Written() bool {
+	return c.Skipped == "" && c.Error == ""
+}
+
+// PushLabels writes reviewed labels into the products array of the matching
+// suggestion documents. Only entries whose labels actually change are
+// updated and returned; a productid listed more than once has all its entries
+// updated. With dryRun nothing is written. Every read and update gets its own
+// timeout; one that fails is returned with its Error set and the others still
+// go ahead, so the error only says how many failed.
+func PushLabels(ctx context.Context, client *mongo.Client, cfg *configs.MongoConfig, updates []LabelUpdate, dryRun bool, timeout time.Duration) ([]LabelChange, error) {
+	coll := client.Database(cfg.Database).Collection(cfg.SuggestionCollection)
+
+	bySuggestion := make(map[primitive.ObjectID][]LabelUpdate)
+	var order []primitive.ObjectID
+	for _, u := range updates {
+		if _, ok := bySuggestion[u.SuggestionID]; !ok {
+			order = append(order, u.SuggestionID)
+		}
+		bySuggestion[u.SuggestionID] = append(bySuggestion[u.SuggestionID], u)
+	}
+
+	var changes []LabelChange
+	failed := 0
+	for _, id := range order {
+		if ctx.Err() != nil {
+			for _, u := range bySuggestion[id] {
+				changes = append(changes, failedChange(u, dryRun, ctx.Err()))
+				failed++
+			}
+			continue
+		}
+		var doc model.SuggestionProduct
+		findCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := coll.FindOne(findCtx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"products": 1})).Decode(&doc)
+		cancel()
+		if err == mongo.ErrNoDocuments {
+			for _, u := range bySuggestion[id] {
+				changes = append(changes, skipped(u, dryRun, "suggestion not found"))
+			}
+			continue
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to read suggestion %s: %w", id.Hex(), err)
+			for _, u := range bySuggestion[id] {
+				changes = append(changes, failedChange(u, dryRun, err))
+				failed++
+			}
+			continue
+		}
+
+		// a productid can appear more than once; every entry of it is updated
+		current := make(map[string][]Labels, len(doc.Products))
+		for _, p := range doc.Products {
+			current[p.ProductID] = append(current[p.ProductID], Labels{Matching: p.Matching, Similar: p.Similar})
+		}
+
+		for _, u := range bySuggestion[id] {
+			entries, ok := current[u.ProductID]
+			if !ok {
+				changes = append(changes, skipped(u, dryRun, "product not in suggestion"))
+				continue
+			}
+			labels := Labels{Matching: u.Matching, Similar: u.Similar}
+			i := slices.IndexFunc(entries, func(l Labels) bool { return l != labels })
+			if i < 0 {
+				continue
+			}
+			old := entries[i]
+
+			change := LabelChange{
+				Time:         time.Now(),
+				DryRun:       dryRun,
+				SuggestionID: id.Hex(),
+				ProductID:    u.ProductID,
+				Old:          old,
+				New:          labels,
+			}
+			if !dryRun {
+				filter := bson.M{"_id": id}
+				update := bson.M{"$set": bson.M{
+					"products.$[p].matching": u.Matching,
+					"products.$[p].similar":  u.Similar,
+				}}
+				opts := options.Update().SetArrayFilters(options.ArrayFilters{
+					Filters: []any{bson.M{"p.productid": u.ProductID}},
+				})
+				updateCtx, cancel := context.WithTimeout(ctx, timeout)
+				_, err := coll.UpdateOne(updateCtx, filter, update, opts)
+				cancel()
+				if err != nil {
+					change.Error = fmt.Sprintf("failed to update suggestion %s product %s: %v", id.Hex(), u.ProductID, err)
+					failed++
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+	if failed > 0 {
+		return changes, fmt.Errorf("failed to write %d labels", failed)
+	}
+	return changes, nil
+}
+
+func skipped(u LabelUpdate, dryRun bool, reason string) LabelChange {
+	return LabelChange{
+		Time:         time.Now(),
+		DryRun:       dryRun,
+		SuggestionID: u.SuggestionID.Hex(),
+		ProductID:    u.ProductID,
+		New:          Labels{Matching: u.Matching, Similar: u.Similar},
+		Skipped:      reason,
+	}
+}
+
+func failedChange(u LabelUpdate, dryRun bool, err error) LabelChange {
+	c := skipped(u, dryRun, "")
+	c.Error = err.Error()
+	return c
+}
+
+// AppendAuditLog appends changes to an NDJSON audit log
+func AppendAuditLog(path string, changes []LabelChange) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return fmt.Errorf("failed to write audit log %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// TestPushLabels runs against the mongod of MONGO_TEST_URI, like
+// TestLoadShopGroups
+func TestPushLabels(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cfg := &configs.MongoConfig{
+		URI:                  uri,
+		Database:             fmt.Sprintf("letsgo_test_%d", time.Now().UnixNano()),
+		SuggestionCollection: "suggestion_products",
+	}
+	client, err := Connect(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	db := client.Database(cfg.Database)
+	defer db.Drop(context.Background())
+	coll := db.Collection(cfg.SuggestionCollection)
+
+	id := primitive.NewObjectID()
+	_, err = coll.InsertOne(ctx, bson.M{"_id": id, "products": []bson.M{
+		{"productid": "a", "matching": false, "similar": false},
+		{"productid": "b", "matching": true, "similar": false},
+		{"productid": "a", "matching": false, "similar": true}, // listed twice
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := []LabelUpdate{
+		{SuggestionID: id, ProductID: "a", Matching: true},
+		{SuggestionID: id, ProductID: "b", Matching: true},      // unchanged
+		{SuggestionID: id, ProductID: "c", Similar: true},       // not in the suggestion
+		{SuggestionID: primitive.NewObjectID(), ProductID: "a"}, // no such suggestion
+	}
+	changes, err := PushLabels(ctx, client, cfg, updates, false, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type outcome struct {
+		productID string
+		written   bool
+		skipped   string
+	}
+	var got []outcome
+	for _, c := range changes {
+		got = append(got, outcome{c.ProductID, c.Written(), c.Skipped})
+	}
+	want := []outcome{
+		{"a", true, ""},
+		{"c", false, "product not in suggestion"},
+		{"a", false, "suggestion not found"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes %+v, want %+v", got, want)
+	}
+
+	var doc model.SuggestionProduct
+	if err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	var labels []Labels
+	for _, p := range doc.Products {
+		labels = append(labels, Labels{Matching: p.Matching, Similar: p.Similar})
+	}
+	if want := []Labels{{true, false}, {true, false}, {true, false}}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("products labeled %+v, want %+v", labels, want)
+	}
+}
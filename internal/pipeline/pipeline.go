@@ -0,0 +1,240 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pipeline chains stages of bounded worker pools with channels. A stage only
+// takes a new item once the next one has room for its result, so a slow stage
+// holds back the ones before it instead of piling up work.
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	stages []*counters
+	errs   []error
+}
+
+// Metrics is what one stage did over a run
+type Metrics struct {
+	Name    string        `json:"name"`
+	Workers int           `json:"workers"`
+	Items   int64         `json:"items"`
+	Busy    time.Duration `json:"busy_ns"`    // working, summed over workers
+	Idle    time.Duration `json:"idle_ns"`    // waiting for input, summed over workers
+	Blocked time.Duration `json:"blocked_ns"` // waiting for the next stage to take a result
+}
+
+type counters struct {
+	name    string
+	workers int
+	items   atomic.Int64
+	busy    atomic.Int64
+	idle    atomic.Int64
+	blocked atomic.Int64
+}
+
+// New returns an empty pipeline whose context derives from ctx
+func New(ctx context.Context) *Pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Pipeline{ctx: ctx, cancel: cancel}
+}
+
+// Context is cancelled once Drain's fn fails or Drain returns, for the
+// source and stages to stop starting work
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+func (p *Pipeline) add(name string, workers int) *counters {
+	c := &counters{name: name, workers: workers}
+	p.mu.Lock()
+	p.stages = append(p.stages, c)
+	p.mu.Unlock()
+	return c
+}
+
+func (p *Pipeline) fail(err error) {
+	p.mu.Lock()
+	p.errs = append(p.errs, err)
+	p.mu.Unlock()
+}
+
+// Source runs fn in its own goroutine and sends every item it emits on the
+// returned channel, which is closed once fn returns. An error of fn is
+// returned by Drain.
+func Source[T any](p *Pipeline, name string, fn func(emit func(T)) error) <-chan T {
+	c := p.add(name, 1)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		start := time.Now()
+		err := fn(func(item T) {
+			c.items.Add(1)
+			send(c, out, item)
+		})
+		c.busy.Add(int64(time.Since(start)) - c.blocked.Load())
+		if err != nil {
+			p.fail(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+	return out
+}
+
+// Stage starts workers goroutines applying fn to the items of in and returns
+// the channel of their results, in completion order. The channel is closed
+// once in is closed and every item is done.
+func Stage[In, Out any](p *Pipeline, name string, workers int, in <-chan In, fn func(In) Out) <-chan Out {
+	workers = max(workers, 1)
+	c := p.add(name, workers)
+	out := make(chan Out, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				wait := time.Now()
+				item, ok := <-in
+				c.idle.Add(int64(time.Since(wait)))
+				if !ok {
+					return
+				}
+				start := time.Now()
+				res := fn(item)
+				c.busy.Add(int64(time.Since(start)))
+				c.items.Add(1)
+				send(c, out, res)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Tee sends every item of in to both returned channels, so two stages can
+// work on the same items side by side. Each channel buffers up to buffer
+// items, which is how far the faster stage may run ahead before it waits for
+// the slower one; the channels are closed once in is.
+func Tee[T any](in <-chan T, buffer int) (<-chan T, <-chan T) {
+	outA, outB := make(chan T, buffer), make(chan T, buffer)
+	go func() {
+		defer close(outA)
+		defer close(outB)
+		for item := range in {
+			a, b := outA, outB
+			for a != nil || b != nil {
+				select {
+				case a <- item:
+					a = nil
+				case b <- item:
+					b = nil
+				}
+			}
+		}
+	}()
+	return outA, outB
+}
+
+// Join passes on an item once it has arrived on both a and b, as after the
+// stages fed by Tee. Items must be the same values on both, e.g. pointers.
+// The returned channel is closed once a and b are.
+func Join[T comparable](a, b <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		pending := make(map[T]bool)
+		arrived := func(item T) {
+			if !pending[item] {
+				pending[item] = true
+				return
+			}
+			delete(pending, item)
+			out <- item
+		}
+		for a != nil || b != nil {
+			select {
+			case item, ok := <-a:
+				if !ok {
+					a = nil
+					continue
+				}
+				arrived(item)
+			case item, ok := <-b:
+				if !ok {
+					b = nil
+					continue
+				}
+				arrived(item)
+			}
+		}
+	}()
+	return out
+}
+
+// Drain hands every item of in to fn in the calling goroutine. The first
+// error of fn cancels the pipeline's context; the remaining items are still
+// taken, so the stages before can finish, but handed to rest instead, when
+// not nil. It returns the errors of fn and of the source.
+func Drain[T any](p *Pipeline, name string, in <-chan T, fn func(T) error, rest func(T)) error {
+	defer p.cancel()
+	c := p.add(name, 1)
+	var drainErr error
+	for {
+		wait := time.Now()
+		item, ok := <-in
+		c.idle.Add(int64(time.Since(wait)))
+		if !ok {
+			break
+		}
+		if drainErr != nil {
+			if rest != nil {
+				rest(item)
+			}
+			continue
+		}
+		start := time.Now()
+		drainErr = fn(item)
+		c.busy.Add(int64(time.Since(start)))
+		c.items.Add(1)
+		if drainErr != nil {
+			p.cancel()
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return errors.Join(append(p.errs, drainErr)...)
+}
+
+func send[T any](c *counters, out chan<- T, item T) {
+	start := time.Now()
+	out <- item
+	c.blocked.Add(int64(time.Since(start)))
+}
+
+// Metrics returns the counters of every stage, in the order they were added
+func (p *Pipeline) Metrics() []Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ms := make([]Metrics, len(p.stages))
+	for i, c := range p.stages {
+		ms[i] = Metrics{
+			Name:    c.name,
+			Workers: c.workers,
+			Items:   c.items.Load(),
+			Busy:    time.Duration(c.busy.Load()),
+			Idle:    time.Duration(c.idle.Load()),
+			Blocked: time.Duration(c.blocked.Load()),
+		}
+	}
+	return ms
+}
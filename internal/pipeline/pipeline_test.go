@@ -0,0 +1,234 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	errWrite := errors.New("disk full")
+	tests := []struct {
+		name     string
+		failAt   int // item fn fails on, -1 for none
+		wantDone []int
+		wantRest []int
+	}{
+		{"all written", -1, []int{0, 1, 2, 3, 4}, nil},
+		{"first fails", 0, []int{0}, []int{1, 2, 3, 4}},
+		{"middle fails", 2, []int{0, 1, 2}, []int{3, 4}},
+		{"last fails", 4, []int{0, 1, 2, 3, 4}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(context.Background())
+			src := Source(p, "source", func(emit func(int)) error {
+				for i := range 5 {
+					emit(i)
+				}
+				return nil
+			})
+			var done, rest []int
+			err := Drain(p, "drain", src, func(i int) error {
+				done = append(done, i)
+				if i == tt.failAt {
+					return errWrite
+				}
+				return nil
+			}, func(i int) {
+				rest = append(rest, i)
+			})
+			if tt.failAt < 0 {
+				if err != nil {
+					t.Fatalf("Drain = %v", err)
+				}
+			} else if !errors.Is(err, errWrite) {
+				t.Fatalf("Drain = %v, want %v", err, errWrite)
+			}
+			if !reflect.DeepEqual(done, tt.wantDone) || !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("fn got %v, rest got %v, want %v and %v", done, rest, tt.wantDone, tt.wantRest)
+			}
+			if p.Context().Err() == nil {
+				t.Error("context not cancelled once Drain returned")
+			}
+		})
+	}
+}
+
+func TestDrainCancels(t *testing.T) {
+	p := New(context.Background())
+	ctx := p.Context()
+	cancelledAt := -1
+	src := Source(p, "source", func(emit func(int)) error {
+		for i := range 100 {
+			if cancelledAt < 0 && ctx.Err() != nil {
+				cancelledAt = i
+			}
+			emit(i)
+		}
+		return nil
+	})
+	// a stage starting work only while the context is live, as provider calls do
+	var calls []int
+	out := Stage(p, "call", 1, src, func(i int) int {
+		if ctx.Err() == nil {
+			calls = append(calls, i)
+		}
+		return i
+	})
+	rest := 0
+	err := Drain(p, "drain", out, func(i int) error {
+		if i == 3 {
+			return errors.New("write failed")
+		}
+		return nil
+	}, func(int) { rest++ })
+	if err == nil {
+		t.Fatal("Drain succeeded")
+	}
+	if cancelledAt < 0 || cancelledAt > 10 {
+		t.Errorf("source saw the cancellation at item %d", cancelledAt)
+	}
+	if len(calls) > 10 {
+		t.Errorf("stage kept working after the failure: %d calls", len(calls))
+	}
+	if rest != 96 {
+		t.Errorf("rest got %d items, want 96", rest)
+	}
+}
+
+func TestDrainSourceError(t *testing.T) {
+	p := New(context.Background())
+	src := Source(p, "source", func(emit func(int)) error {
+		emit(1)
+		return errors.New("truncated input")
+	})
+	if err := Drain(p, "drain", src, func(int) error { return nil }, nil); err == nil {
+		t.Error("Drain dropped the source error")
+	}
+}
+
+func TestTee(t *testing.T) {
+	tests := []struct {
+		name   string
+		buffer int
+		items  int
+	}{
+		{"unbuffered", 0, 5},
+		{"buffered", 3, 10},
+		{"empty", 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan int)
+			go func() {
+				defer close(in)
+				for i := range tt.items {
+					in <- i
+				}
+			}()
+			a, b := Tee(in, tt.buffer)
+			var gotA, gotB []int
+			for a != nil || b != nil {
+				select {
+				case i, ok := <-a:
+					if !ok {
+						a = nil
+						continue
+					}
+					gotA = append(gotA, i)
+				case i, ok := <-b:
+					if !ok {
+						b = nil
+						continue
+					}
+					gotB = append(gotB, i)
+				}
+			}
+			var want []int
+			for i := range tt.items {
+				want = append(want, i)
+			}
+			if !reflect.DeepEqual(gotA, want) || !reflect.DeepEqual(gotB, want) {
+				t.Errorf("Tee sent %v and %v, want %v on both", gotA, gotB, want)
+			}
+		})
+	}
+}
+
+func TestTeeRunsAhead(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := range 10 {
+			in <- i
+		}
+	}()
+	a, b := Tee(in, 4)
+
+	// b is not read at all: a still gets as many items as b can buffer, and
+	// then one more
+	var got []int
+	timeout := time.After(time.Second)
+	for len(got) < 5 {
+		select {
+		case i := <-a:
+			got = append(got, i)
+		case <-timeout:
+			t.Fatalf("a got %v while b was not read", got)
+		}
+	}
+	select {
+	case i := <-a:
+		t.Errorf("a got %d, more than the buffer ahead of b", i)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// once b catches up both get everything
+	gotB := make(chan []int)
+	go func() {
+		var items []int
+		for i := range b {
+			items = append(items, i)
+		}
+		gotB <- items
+	}()
+	for i := range a {
+		got = append(got, i)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !slices.Equal(got, want) || !slices.Equal(<-gotB, want) {
+		t.Errorf("a got %v, want %v on both", got, want)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	a, b := make(chan *int), make(chan *int)
+	items := []*int{new(int), new(int), new(int)}
+	go func() {
+		defer close(a)
+		for _, item := range items {
+			a <- item
+		}
+	}()
+	go func() {
+		defer close(b)
+		for _, item := range slices.Backward(items) {
+			b <- item
+		}
+	}()
+	var got []*int
+	for item := range Join(a, b) {
+		got = append(got, item)
+	}
+	if len(got) != len(items) {
+		t.Fatalf("Join passed %d items, want %d", len(got), len(items))
+	}
+	for _, item := range items {
+		if !slices.Contains(got, item) {
+			t.Errorf("Join dropped %p", item)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Display modes
+const (
+	ModeAuto  = "auto"  // live line on a terminal, plain lines otherwise
+	ModeTTY   = "tty"   // redraw one line in place
+	ModePlain = "plain" // print a new line every PlainInterval
+	ModeOff   = "off"
+)
+
+// Intervals between two renderings of each mode
+const (
+	TTYInterval   = 250 * time.Millisecond
+	PlainInterval = 10 * time.Second
+)
+
+// Tracker counts the progress of a run. Its methods may be called from any
+// goroutine, and on a nil Tracker, which tracks nothing.
+type Tracker struct {
+	providers []string
+
+	start   atomic.Int64 // unix nanoseconds of the first call, 0 before it
+	total   atomic.Int64 // 0 while unknown
+	done    atomic.Int64
+	reused  atomic.Int64
+	calls   map[string]*atomic.Int64 // in flight, per provider
+	errors  map[string]*atomic.Int64
+	mu      sync.Mutex // guards out and drawn
+	out     io.Writer
+	live    bool
+	drawn   bool
+	stopped chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New returns a tracker counting calls to providers
+func New(providers ...string) *Tracker {
+	t := &Tracker{
+		providers: providers,
+		calls:     make(map[string]*atomic.Int64),
+		errors:    make(map[string]*atomic.Int64),
+	}
+	for _, p := range providers {
+		t.calls[p] = new(atomic.Int64)
+		t.errors[p] = new(atomic.Int64)
+	}
+	return t
+}
+
+// SetTotal sets the number of products the run will process
+func (t *Tracker) SetTotal(n int) {
+	if t != nil {
+		t.total.Store(int64(n))
+	}
+}
+
+// Call marks a request to provider as in flight. The returned function ends
+// it, counting an error when err is not nil. The first call starts the clock
+// of the ETA, so loading the input does not count towards it.
+func (t *Tracker) Call(provider string) func(err error) {
+	if t == nil || t.calls[provider] == nil {
+		return func(error) {}
+	}
+	t.start.CompareAndSwap(0, time.Now().UnixNano())
+	t.calls[provider].Add(1)
+	return func(err error) {
+		t.calls[provider].Add(-1)
+		if err != nil {
+			t.errors[provider].Add(1)
+		}
+	}
+}
+
+// Done counts a finished product. reused products were kept from a
+// checkpoint or an earlier report without calling the providers.
+func (t *Tracker) Done(reused bool) {
+	if t == nil {
+		return
+	}
+	t.done.Add(1)
+	if reused {
+		t.reused.Add(1)
+	}
+}
+
+// Start renders the progress to out until Stop, in the given mode. In tty
+// mode, other output should go through the tracker's Write so it doesn't get
+// mixed into the progress line.
+func (t *Tracker) Start(out *os.File, mode string) error {
+	if t == nil {
+		return nil
+	}
+	interval := PlainInterval
+	switch mode {
+	case ModeAuto:
+		if isTerminal(out) {
+			t.live, interval = true, TTYInterval
+		}
+	case ModeTTY:
+		t.live, interval = true, TTYInterval
+	case ModePlain:
+	case ModeOff:
+		return nil
+	default:
+		return fmt.Errorf("unknown progress mode %q, expected auto, tty, plain or off", mode)
+	}
+	t.mu.Lock()
+	t.out = out
+	t.mu.Unlock()
+	t.stopped = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.render()
+			case <-t.stopped:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the rendering with a last, final line
+func (t *Tracker) Stop() {
+	if t == nil || t.stopped == nil {
+		return
+	}
+	close(t.stopped)
+	t.wg.Wait()
+	t.stopped = nil
+	t.render()
+	t.mu.Lock()
+	if t.live && t.drawn {
+		fmt.Fprintln(t.out)
+	}
+	t.drawn = false
+	t.mu.Unlock()
+}
+
+// Write writes p to the tracker's output, above the progress line when it
+// is live. Writes before Start or after Stop go to stderr.
+func (t *Tracker) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.out == nil {
+		return os.Stderr.Write(p)
+	}
+	if !t.live || !t.drawn {
+		return t.out.Write(p)
+	}
+	fmt.Fprint(t.out, "\r\033[K")
+	n, err := t.out.Write(p)
+	fmt.Fprint(t.out, t.line())
+	return n, err
+}
+
+func (t *Tracker) render() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.live {
+		fmt.Fprint(t.out, "\r\033[K"+t.line())
+		t.drawn = true
+	} else {
+		fmt.Fprintln(t.out, t.line())
+	}
+}
+
+// line describes the progress, e.g.
+// ⏳ 12/40 products (30%) · ETA 2m10s · in flight alihunter 3, aliexpress 2 · errors alihunter 1 · reused 5
+func (t *Tracker) line() string {
+	total, done, reused := t.total.Load(), t.done.Load(), t.reused.Load()
+	var elapsed time.Duration
+	if start := t.start.Load(); start != 0 {
+		elapsed = time.Since(time.Unix(0, start))
+	}
+
+	parts := make([]string, 0, 5)
+	if total > 0 {
+		parts = append(parts, fmt.Sprintf("⏳ %d/%d products (%d%%)", done, total, done*100/total))
+	} else {
+		parts = append(parts, fmt.Sprintf("⏳ %d products", done))
+	}
+	// reused products take no time, so they don't count towards the rate
+	if fetched := done - reused; fetched > 0 && total > done && elapsed > 0 {
+		eta := elapsed / time.Duration(fetched) * time.Duration(total-done)
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	} else if total == 0 && elapsed > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f/s", float64(done)/elapsed.Seconds()))
+	}
+	parts = append(parts, "in flight "+t.perProvider(t.calls))
+	parts = append(parts, "errors "+t.perProvider(t.errors))
+	parts = append(parts, fmt.Sprintf("reused %d", reused))
+	return strings.Join(parts, " · ")
+}
+
+func (t *Tracker) perProvider(counts map[string]*atomic.Int64) string {
+	list := make([]string, len(t.providers))
+	for i, p := range t.providers {
+		list[i] = fmt.Sprintf("%s %d", p, counts[p].Load())
+	}
+	return strings.Join(list, ", ")
+}
+
+// isTerminal reports whether f is a character device, i.e. most likely a
+// terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
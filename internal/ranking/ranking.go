@@ -0,0 +1,159 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+)
+
+// Weights of each signal in the ranking score. Every signal is min-max
+// normalized within a provider's result list before weighting, so weights are
+// comparable to each other. Price is inverted: the cheapest candidate scores 1.
+type Weights struct {
+	Rating     float64 `json:"rating"`
+	Volume     float64 `json:"volume"`
+	Reviews    float64 `json:"reviews"`
+	Price      float64 `json:"price"`
+	Similarity float64 `json:"similarity"`
+}
+
+// IsZero reports whether no signal is weighted, i.e. upstream order is kept
+func (w Weights) IsZero() bool {
+	return w == Weights{}
+}
+
+// ParseWeights parses "rating=1,volume=0.5,price=0.2" style definitions.
+// Signals that are not mentioned get a weight of 0.
+func ParseWeights(s string) (Weights, error) {
+	var w Weights
+	if strings.TrimSpace(s) == "" {
+		return w, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return w, fmt.Errorf("invalid weight %q, expected name=value", part)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return w, fmt.Errorf("invalid weight value %q: %w", value, err)
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "rating":
+			w.Rating = v
+		case "volume", "sales":
+			w.Volume = v
+		case "reviews":
+			w.Reviews = v
+		case "price":
+			w.Price = v
+		case "similarity":
+			w.Similarity = v
+		default:
+			return w, fmt.Errorf("unknown ranking signal %q", name)
+		}
+	}
+	return w, nil
+}
+
+// String formats the weights the way ParseWeights reads them
+func (w Weights) String() string {
+	return fmt.Sprintf("rating=%g,volume=%g,reviews=%g,price=%g,similarity=%g",
+		w.Rating, w.Volume, w.Reviews, w.Price, w.Similarity)
+}
+
+// Scores computes the weighted score of every candidate in the list
+func Scores(cs []candidate.Candidate, w Weights) []float64 {
+	rating := make([]float64, len(cs))
+	volume := make([]float64, len(cs))
+	reviews := make([]float64, len(cs))
+	price := make([]float64, len(cs))
+	similarity := make([]float64, len(cs))
+	for i, c := range cs {
+		rating[i] = c.Rating
+		// sales and reviews are heavy-tailed, compare them on a log scale
+		volume[i] = math.Log1p(float64(max(c.Volume, 0)))
+		reviews[i] = math.Log1p(float64(max(c.Reviews, 0)))
+		price[i] = c.Price
+		similarity[i] = c.Similarity
+	}
+	// unknown prices must not look like the cheapest offer
+	highest := 0.0
+	for _, p := range price {
+		highest = math.Max(highest, p)
+	}
+	for i, p := range price {
+		if p <= 0 {
+			price[i] = highest
+		}
+	}
+
+	normalize(rating, false)
+	normalize(volume, false)
+	normalize(reviews, false)
+	normalize(price, true)
+	normalize(similarity, false)
+
+	scores := make([]float64, len(cs))
+	for i := range cs {
+		scores[i] = w.Rating*rating[i] + w.Volume*volume[i] + w.Reviews*reviews[i] +
+			w.Price*price[i] + w.Similarity*similarity[i]
+	}
+	return scores
+}
+
+// Sort re-orders items by descending score, keeping upstream order for ties,
+// and hands each item its score through setScore
+func Sort[T any](items []T, conv func(T) candidate.Candidate, w Weights, setScore func(*T, float64)) {
+	if w.IsZero() || len(items) == 0 {
+		return
+	}
+	cs := make([]candidate.Candidate, len(items))
+	for i, item := range items {
+		cs[i] = conv(item)
+	}
+	scores := Scores(cs, w)
+
+	type scored struct {
+		item  T
+		score float64
+	}
+	ranked := make([]scored, len(items))
+	for i := range items {
+		ranked[i] = scored{item: items[i], score: scores[i]}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	for i := range ranked {
+		items[i] = ranked[i].item
+		if setScore != nil {
+			setScore(&items[i], ranked[i].score)
+		}
+	}
+}
+
+// normalize maps values onto [0,1] in place. A list without spread scores 0.
+func normalize(values []float64, invert bool) {
+	if len(values) == 0 {
+		return
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	for i, v := range values {
+		if hi == lo {
+			values[i] = 0
+			continue
+		}
+		values[i] = (v - lo) / (hi - lo)
+		if invert {
+			values[i] = 1 - values[i]
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package ranking
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     []float64
+		invert bool
+		want   []float64
+	}{
+		{"empty", nil, false, nil},
+		{"spread", []float64{2, 4, 6}, false, []float64{0, 0.5, 1}},
+		{"inverted", []float64{2, 4, 6}, true, []float64{1, 0.5, 0}},
+		{"negative", []float64{-1, 1}, false, []float64{0, 1}},
+		{"no spread", []float64{3, 3, 3}, false, []float64{0, 0, 0}},
+		{"no spread inverted", []float64{3, 3}, true, []float64{0, 0}},
+		{"single", []float64{7}, false, []float64{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := append([]float64(nil), tt.in...)
+			normalize(got, tt.invert)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalize(%v, %v) = %v, want %v", tt.in, tt.invert, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScores(t *testing.T) {
+	tests := []struct {
+		name string
+		cs   []candidate.Candidate
+		w    Weights
+		want []float64
+	}{
+		{
+			"rating",
+			[]candidate.Candidate{{Rating: 3}, {Rating: 5}, {Rating: 4}},
+			Weights{Rating: 1},
+			[]float64{0, 1, 0.5},
+		},
+		{
+			"cheapest scores highest",
+			[]candidate.Candidate{{Price: 10}, {Price: 20}},
+			Weights{Price: 1},
+			[]float64{1, 0},
+		},
+		{
+			"unknown price counts as the highest",
+			[]candidate.Candidate{{Price: 10}, {Price: 0}, {Price: 20}},
+			Weights{Price: 1},
+			[]float64{1, 0, 0},
+		},
+		{
+			"volume on a log scale",
+			[]candidate.Candidate{{Volume: 0}, {Volume: 9}, {Volume: 99}},
+			Weights{Volume: 1},
+			[]float64{0, 0.5, 1},
+		},
+		{
+			"negative reviews count as none",
+			[]candidate.Candidate{{Reviews: -5}, {Reviews: 0}, {Reviews: 99}},
+			Weights{Reviews: 1},
+			[]float64{0, 0, 1},
+		},
+		{
+			"weights add up",
+			[]candidate.Candidate{{Rating: 5, Similarity: 0.2}, {Rating: 3, Similarity: 0.9}},
+			Weights{Rating: 1, Similarity: 2},
+			[]float64{1, 2},
+		},
+		{
+			"no spread",
+			[]candidate.Candidate{{Rating: 4}, {Rating: 4}},
+			Weights{Rating: 1},
+			[]float64{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Scores(tt.cs, tt.w)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Scores = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("Scores = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	type item struct {
+		id     string
+		rating float64
+	}
+	conv := func(it item) candidate.Candidate { return candidate.Candidate{ProductID: it.id, Rating: it.rating} }
+	tests := []struct {
+		name  string
+		items []item
+		w     Weights
+		want  []string
+	}{
+		{"descending score", []item{{"a", 3}, {"b", 5}, {"c", 4}}, Weights{Rating: 1}, []string{"b", "c", "a"}},
+		{"ties keep upstream order", []item{{"a", 4}, {"b", 5}, {"c", 4}}, Weights{Rating: 1}, []string{"b", "a", "c"}},
+		{"zero weights keep upstream order", []item{{"a", 3}, {"b", 5}}, Weights{}, []string{"a", "b"}},
+		{"empty", nil, Weights{Rating: 1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := append([]item(nil), tt.items...)
+			scores := make(map[string]float64)
+			Sort(items, conv, tt.w, func(it *item, score float64) { scores[it.id] = score })
+			var got []string
+			for _, it := range items {
+				got = append(got, it.id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sort = %v, want %v", got, tt.want)
+			}
+			if !tt.w.IsZero() && len(scores) != len(items) {
+				t.Errorf("setScore called for %v, want every item", scores)
+			}
+		})
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Weights
+		wantErr bool
+	}{
+		{"", Weights{}, false},
+		{"rating=1,volume=0.5", Weights{Rating: 1, Volume: 0.5}, false},
+		{" Sales = 2 , price=0.3", Weights{Volume: 2, Price: 0.3}, false},
+		{"reviews=1,similarity=1", Weights{Reviews: 1, Similarity: 1}, false},
+		{"rating", Weights{}, true},
+		{"rating=high", Weights{}, true},
+		{"color=1", Weights{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWeights(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWeights(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseWeights(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if !tt.wantErr {
+			if again, err := ParseWeights(got.String()); err != nil || again != got {
+				t.Errorf("ParseWeights(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+			}
+		}
+	}
+}
@@ -1,54 +1,59 @@
 package rapidapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 
 	"github.com/quanghia24/letsgo/configs"
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/httpclient"
 	"github.com/quanghia24/letsgo/internal/model"
 )
 
 // AliExpressSearchByImage fetches products from AliExpress API with endpoint get from .env
-// Return top 3 products
-func AliExpressSearchByImage(image string) ([]model.AliExpressProduct, []model.AliExpressProduct, error) {
+// Return every product with an image, in upstream order
+func AliExpressSearchByImage(ctx context.Context, image string) (products []model.AliExpressProduct, err error) {
 	if image == "" {
-		return nil, nil, fmt.Errorf("image URL is empty")
+		return nil, fmt.Errorf("image URL is empty")
 	}
 
+	call := httpclient.Start(candidate.ProviderAliExpress)
+	defer func() { call.End(err) }()
+
 	// URL encode the image parameter to handle special characters
 	encodedImage := url.QueryEscape(image)
 	serviceURL := fmt.Sprintf("https://%s/item_search_image?sort=default&catId=0&imgUrl=%s", configs.GetRapidAPIConfig().Host, encodedImage)
 
-	req, err := http.NewRequest(http.MethodGet, serviceURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serviceURL, nil)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("X-RapidAPI-Key", configs.GetRapidAPIConfig().APIKey)
 	req.Header.Set("X-RapidAPI-Host", configs.GetRapidAPIConfig().Host)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to perform request: %w", err)
+		return nil, fmt.Errorf("failed to perform request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check HTTP status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, resp.Status)
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return nil, err
 	}
 
 	var data model.AliExpressSearchByImageResponse
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, nil, fmt.Errorf("failed to decode response body: %w", err)
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
 	}
 
 	// Debug: log the response for troubleshooting
-	log.Printf("AliExpress API Response - URL: %s, Results count: %d", serviceURL, len(data.Result.ResultList))
+	slog.Debug("aliexpress response", "url", serviceURL, "results", len(data.Result.ResultList))
 
-	var products []model.AliExpressProduct
 	var originProducts []model.AliExpressProduct
 
 	for _, result := range data.Result.ResultList {
@@ -77,26 +82,15 @@ func AliExpressSearchByImage(image string) ([]model.AliExpressProduct, []model.A
 			Title:         item.Title,
 			ImageURL:      item.Image,
 			AvgRatingStar: avgRating,
+			HasRating:     item.AverageStarRate != nil,
 			Volume:        item.Sales,
 			SalePrice:     item.Sku.Def.PromotionPrice,
 			OriginalPrice: price,
 		}
 
 		originProducts = append(originProducts, product)
-
-		// Only add to filtered products if it has ratings
-		if item.AverageStarRate != nil {
-			products = append(products, product)
-		}
-	}
-
-	// get max 3 products
-	if len(products) > 3 {
-		products = products[:3]
-	}
-	if len(originProducts) > 3 {
-		originProducts = originProducts[:3]
 	}
 
-	return products, originProducts, nil
+	call.Results(len(originProducts))
+	return originProducts, nil
 }
@@ -0,0 +1,64 @@
+package report
+
+import "github.com/quanghia24/letsgo/internal/candidate"
+
+// Candidates returns one of the six candidate columns of a report as
+// normalized candidates. origin selects the unfiltered column, for the local
+// provider as for the others. The HTML report only shows the local origin
+// column, so local labels are found there; LabeledCandidates reads both.
+func (r Report) Candidates(provider string, origin bool) []candidate.Candidate {
+	var out []candidate.Candidate
+	switch provider {
+	case candidate.ProviderLocal:
+		items := r.LocalRapidAPIOrigin
+		if !origin {
+			items = r.LocalRapidAPITop
+		}
+		for _, p := range items {
+			out = append(out, candidate.FromLocal(p))
+		}
+	case candidate.ProviderAliHunter:
+		items := r.AliHunterOrigin
+		if !origin {
+			items = r.AliHunterTop
+		}
+		for _, p := range items {
+			out = append(out, candidate.FromAliHunter(p))
+		}
+	case candidate.ProviderAliExpress:
+		items := r.AliExpressOrigin
+		if !origin {
+			items = r.AliExpressTop
+		}
+		for _, p := range items {
+			out = append(out, candidate.FromAliExpress(p))
+		}
+	}
+	return out
+}
+
+// LabeledCandidates merges the filtered and origin columns of a provider,
+// de-duplicated by product ID. A candidate counts as labeled Matching or
+// Similar when it was ticked in either column; a column where it was
+// reviewed wins over one where it was not.
+func (r Report) LabeledCandidates(provider string) []candidate.Candidate {
+	var out []candidate.Candidate
+	seen := make(map[string]int)
+	for _, origin := range []bool{false, true} {
+		for _, c := range r.Candidates(provider, origin) {
+			if i, ok := seen[c.ProductID]; ok {
+				switch {
+				case c.Reviewed && !out[i].Reviewed:
+					out[i].Matching, out[i].Similar, out[i].Reviewed = c.Matching, c.Similar, true
+				case c.Reviewed == out[i].Reviewed:
+					out[i].Matching = out[i].Matching || c.Matching
+					out[i].Similar = out[i].Similar || c.Similar
+				}
+				continue
+			}
+			seen[c.ProductID] = len(out)
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+)
+
+// DeadLetter records a provider call that failed for a product, so it can be
+// retried later without running everything again
+type DeadLetter struct {
+	Time         time.Time `json:"time"`
+	RunID        string    `json:"run_id,omitempty"`
+	Provider     string    `json:"provider"`
+	SuggestionID string    `json:"suggestion_id,omitempty"`
+	ShopID       int64     `json:"shop_id"`
+	ProductID    int64     `json:"product_id"`
+	ImageURL     string    `json:"image_url"`
+	Error        string    `json:"error"`
+	HTTPStatus   int       `json:"http_status,omitempty"`
+	Attempt      int       `json:"attempt,omitempty"` // calls made so far, 1 for the run itself
+}
+
+// Key identifies the product of the entry the way Report.Key does
+func (d DeadLetter) Key() string {
+	return Key(d.SuggestionID, d.ShopID, d.ProductID)
+}
+
+// DeadLetters returns an entry for every failed provider call of a report
+func DeadLetters(r Report, runID string) []DeadLetter {
+	var out []DeadLetter
+	for _, p := range []struct {
+		provider string
+		status   ProviderStatus
+	}{
+		{candidate.ProviderAliHunter, r.AliHunterStatus},
+		{candidate.ProviderAliExpress, r.AliExpressStatus},
+	} {
+		if !p.status.Failed() {
+			continue
+		}
+		out = append(out, DeadLetter{
+			Time:         time.Now().UTC(),
+			RunID:        runID,
+			Provider:     p.provider,
+			SuggestionID: r.SuggestionID,
+			ShopID:       r.ShopID,
+			ProductID:    r.ProductID,
+			ImageURL:     r.ImageURL,
+			Error:        p.status.Error,
+			HTTPStatus:   p.status.HTTPStatus,
+			Attempt:      1,
+		})
+	}
+	return out
+}
+
+// AppendDeadLetters appends entries to an NDJSON dead-letter file
+func AppendDeadLetters(path string, entries []DeadLetter) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, d := range entries {
+		if err := enc.Encode(d); err != nil {
+			return fmt.Errorf("failed to write dead-letter file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadDeadLetters reads a dead-letter file
+func LoadDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []DeadLetter
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var d DeadLetter
+		if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("%s:%d: failed to unmarshal dead letter: %w", path, line, err)
+		}
+		entries = append(entries, d)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter file %s: %w", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,193 @@
+package report
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/candidate"
+	"github.com/quanghia24/letsgo/internal/filter"
+	"github.com/quanghia24/letsgo/internal/input"
+)
+
+// SchemaVersion is the version of the report file layout written by this
+// code. Bump it when a change would confuse older readers.
+const SchemaVersion = 1
+
+// Envelope wraps the reports of a run with what produced them. Reports
+// written before the envelope existed are bare arrays and load with a zero
+// SchemaVersion.
+type Envelope struct {
+	SchemaVersion int              `json:"schema_version"`
+	RunID         string           `json:"run_id,omitempty"`
+	StartedAt     time.Time        `json:"started_at,omitzero"`
+	FinishedAt    time.Time        `json:"finished_at,omitzero"`
+	CodeVersion   string           `json:"code_version,omitempty"`
+	Args          []string         `json:"args,omitempty"` // command line of the run
+	Input         InputInfo        `json:"input,omitzero"`
+	Providers     []ProviderConfig `json:"providers,omitempty"`
+	TopN          int              `json:"top_n,omitempty"`
+	Rank          string           `json:"rank,omitempty"` // ranking weights, empty for upstream order
+	Filters       *filter.Config   `json:"filters,omitempty"`
+	Shipping      float64          `json:"shipping,omitempty"`
+	Sample        *input.Sample    `json:"sample,omitempty"`
+	Counts        Counts           `json:"counts"`
+	Incomplete    bool             `json:"incomplete,omitempty"`  // the run was stopped before every product was processed
+	Unprocessed   []ProductRef     `json:"unprocessed,omitempty"` // products of an incomplete run without a report
+	Reports       []Report         `json:"reports"`
+}
+
+// InputInfo identifies the input of a run
+type InputInfo struct {
+	Source string `json:"source"`           // file or mongo
+	Path   string `json:"path"`             // file path, or database.collection
+	SHA256 string `json:"sha256,omitempty"` // of the input file
+}
+
+// ProviderConfig is the configuration a provider was called with. Secrets
+// are redacted before they get here.
+type ProviderConfig struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+	APIKey   string `json:"api_key,omitempty"`
+}
+
+// ProductRef identifies a product of the input
+type ProductRef struct {
+	SuggestionID string `json:"suggestion_id,omitempty"`
+	ShopID       int64  `json:"shop_id"`
+	ProductID    int64  `json:"product_id"`
+	Title        string `json:"title,omitempty"`
+	ImageURL     string `json:"image_url,omitempty"`
+}
+
+// RefOf returns the product a report is about
+func RefOf(r Report) ProductRef {
+	return ProductRef{
+		SuggestionID: r.SuggestionID,
+		ShopID:       r.ShopID,
+		ProductID:    r.ProductID,
+		Title:        r.ProductTitle,
+		ImageURL:     r.ImageURL,
+	}
+}
+
+// Counts summarises the reports of a run
+type Counts struct {
+	Shops          int            `json:"shops"`
+	Products       int            `json:"products"`
+	WithCandidates map[string]int `json:"with_candidates,omitempty"` // products with a non-empty top column, per provider
+	Errors         map[string]int `json:"errors,omitempty"`          // failed provider calls, per provider
+	Reused         int            `json:"reused,omitempty"`          // products kept from a checkpoint or earlier report
+
+	shops map[int64]bool
+}
+
+// Add counts one report
+func (c *Counts) Add(r Report) {
+	if c.shops == nil {
+		c.shops = make(map[int64]bool)
+		c.WithCandidates = make(map[string]int)
+		c.Errors = make(map[string]int)
+	}
+	if !c.shops[r.ShopID] {
+		c.shops[r.ShopID] = true
+		c.Shops++
+	}
+	c.Products++
+	if len(r.LocalRapidAPITop) > 0 {
+		c.WithCandidates[candidate.ProviderLocal]++
+	}
+	if len(r.AliHunterTop) > 0 {
+		c.WithCandidates[candidate.ProviderAliHunter]++
+	}
+	if len(r.AliExpressTop) > 0 {
+		c.WithCandidates[candidate.ProviderAliExpress]++
+	}
+	if r.AliHunterStatus.Failed() {
+		c.Errors[candidate.ProviderAliHunter]++
+	}
+	if r.AliExpressStatus.Failed() {
+		c.Errors[candidate.ProviderAliExpress]++
+	}
+}
+
+// NewEnvelope starts the envelope of a new run
+func NewEnvelope() Envelope {
+	return Envelope{
+		SchemaVersion: SchemaVersion,
+		RunID:         newRunID(),
+		StartedAt:     time.Now().UTC(),
+		CodeVersion:   CodeVersion(),
+		TopN:          TopN,
+	}
+}
+
+// newRunID returns a sortable, unique enough ID such as 20250102T150405Z-1a2b3c4d
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// CodeVersion describes the build: the module version, which for builds
+// from a checkout is a pseudo-version naming the commit, or else the VCS
+// revision
+func CodeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	version, dirty := info.Main.Version, false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			version = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty {
+		version += "-dirty"
+	}
+	return version
+}
+
+// Meta returns the envelope without its reports, as written to NDJSON
+// header and trailer lines
+func (e Envelope) Meta() Envelope {
+	e.Reports = nil
+	return e
+}
+
+// isEnvelopeLine reports whether an NDJSON line holds envelope metadata
+// rather than a report. Envelopes always marshal schema_version first.
+func isEnvelopeLine(line []byte) bool {
+	return bytes.HasPrefix(line, []byte(`{"schema_version"`))
+}
+
+// decodeEnvelope parses a single JSON document holding an envelope
+func decodeEnvelope(path string, data []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, fmt.Errorf("failed to unmarshal %s: %w", path, err)
+	}
+	if err := checkVersion(path, env.SchemaVersion); err != nil {
+		return env, err
+	}
+	return env, nil
+}
+
+func checkVersion(path string, version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("%s has schema version %d, this build reads up to %d", path, version, SchemaVersion)
+	}
+	return nil
+}
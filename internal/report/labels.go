@@ -0,0 +1,61 @@
+package report
+
+import "github.com/quanghia24/letsgo/internal/model"
+
+// labels are the human labels of a candidate. reviewed is only tracked for
+// the local column, where it tells reviewed labels from production flags.
+type labels struct{ matching, similar, reviewed bool }
+
+// labelAccess reads and writes the human labels of one candidate type
+type labelAccess[T any] struct {
+	id  func(T) string
+	get func(T) labels
+	set func(p *T, l labels)
+}
+
+var localLabels = labelAccess[model.ProductItem]{
+	id:  func(p model.ProductItem) string { return p.ProductID },
+	get: func(p model.ProductItem) labels { return labels{p.Matching, p.Similar, p.Reviewed} },
+	set: func(p *model.ProductItem, l labels) {
+		p.Matching, p.Similar, p.Reviewed = l.matching, l.similar, l.reviewed
+	},
+}
+
+var aliHunterLabels = labelAccess[model.AliHunterProduct]{
+	id:  func(p model.AliHunterProduct) string { return p.ProductID },
+	get: func(p model.AliHunterProduct) labels { return labels{matching: p.Matching, similar: p.Similar} },
+	set: func(p *model.AliHunterProduct, l labels) { p.Matching, p.Similar = l.matching, l.similar },
+}
+
+var aliExpressLabels = labelAccess[model.AliExpressProduct]{
+	id:  func(p model.AliExpressProduct) string { return p.ProductID },
+	get: func(p model.AliExpressProduct) labels { return labels{matching: p.Matching, similar: p.Similar} },
+	set: func(p *model.AliExpressProduct, l labels) { p.Matching, p.Similar = l.matching, l.similar },
+}
+
+// CopyLabels carries the human labels of an earlier report of the same
+// product over to r. A candidate keeps its Matching/Similar flags when it
+// shows up again in the same column, and a reviewed local candidate its
+// reviewed labels; labels of candidates that are gone are dropped.
+func (r *Report) CopyLabels(old Report) {
+	copyColumnLabels(localLabels, r.LocalRapidAPITop, old.LocalRapidAPITop)
+	copyColumnLabels(localLabels, r.LocalRapidAPIOrigin, old.LocalRapidAPIOrigin)
+	copyColumnLabels(aliHunterLabels, r.AliHunterTop, old.AliHunterTop)
+	copyColumnLabels(aliHunterLabels, r.AliHunterOrigin, old.AliHunterOrigin)
+	copyColumnLabels(aliExpressLabels, r.AliExpressTop, old.AliExpressTop)
+	copyColumnLabels(aliExpressLabels, r.AliExpressOrigin, old.AliExpressOrigin)
+}
+
+func copyColumnLabels[T any](acc labelAccess[T], dst, src []T) {
+	byID := make(map[string]labels)
+	for _, p := range src {
+		if l := acc.get(p); l != (labels{}) {
+			byID[acc.id(p)] = l
+		}
+	}
+	for i := range dst {
+		if l, ok := byID[acc.id(dst[i])]; ok {
+			acc.set(&dst[i], l)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/quanghia24/letsgo/internal/input"
+)
+
+// NDJSONWriter streams reports to a file, one JSON document per line. Each
+// report is written as soon as it is added, so a crash keeps every completed
+// line. It is safe for concurrent use.
+type NDJSONWriter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// CreateNDJSON creates (or truncates) an NDJSON report file
+func CreateNDJSON(path string) (*NDJSONWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	return &NDJSONWriter{f: f}, nil
+}
+
+// AppendNDJSON opens an NDJSON report for appending, creating it if needed.
+// A truncated last line left by a crash is cut off first so new lines start
+// clean.
+func AppendNDJSON(path string) (*NDJSONWriter, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	end := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to truncate %s: %w", path, err)
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek %s: %w", path, err)
+	}
+	return &NDJSONWriter{f: f}, nil
+}
+
+// Write appends a report as a single line
+func (w *NDJSONWriter) Write(r Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report %d: %w", r.ProductID, err)
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.f.Name(), err)
+	}
+	return nil
+}
+
+// WriteEnvelope appends the run metadata as a line of its own. Runs write it
+// first and again once finished, readers keep the last one.
+func (w *NDJSONWriter) WriteEnvelope(env Envelope) error {
+	data, err := json.Marshal(env.Meta())
+	if err != nil {
+		return fmt.Errorf("failed to marshal run metadata: %w", err)
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.f.Name(), err)
+	}
+	return nil
+}
+
+// Close flushes the file to disk and closes it
+func (w *NDJSONWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return fmt.Errorf("failed to sync %s: %w", w.f.Name(), err)
+	}
+	return w.f.Close()
+}
+
+// WriteRun writes a whole run to path: NDJSON for .ndjson/.jsonl paths,
+// otherwise JSON. It writes a temporary file next to path and renames it over
+// path, so a failed write leaves an existing report, e.g. the input of a
+// patch, as it was.
+func WriteRun(env Envelope, path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	tmp := f.Name()
+	f.Close()
+	if input.IsNDJSON(path) {
+		err = writeNDJSONRun(env, tmp)
+	} else {
+		err = GenerateJSONComparisonReport(env, tmp)
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		if err = os.Rename(tmp, path); err != nil {
+			err = fmt.Errorf("failed to replace %s: %w", path, err)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func writeNDJSONRun(env Envelope, path string) error {
+	w, err := CreateNDJSON(path)
+	if err != nil {
+		return err
+	}
+	err = w.WriteEnvelope(env)
+	for _, r := range env.Reports {
+		if err != nil {
+			break
+		}
+		err = w.Write(r)
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// parseNDJSON decodes one report per non-empty line; envelope lines carry
+// the run metadata. A truncated last line, left by a crash while writing, is
+// ignored.
+func parseNDJSON(path string, data []byte) (Envelope, error) {
+	var env Envelope
+	var reports []Report
+	lines := bytes.Split(data, []byte{'\n'})
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if isEnvelopeLine(line) {
+			meta, err := decodeEnvelope(fmt.Sprintf("%s:%d", path, i+1), line)
+			if err != nil {
+				if i == len(lines)-1 {
+					break
+				}
+				return Envelope{}, err
+			}
+			env = meta
+			continue
+		}
+		var r Report
+		if err := json.Unmarshal(line, &r); err != nil {
+			if i == len(lines)-1 { // no trailing newline: the write was cut short
+				break
+			}
+			return Envelope{}, fmt.Errorf("%s:%d: failed to unmarshal report: %w", path, i+1, err)
+		}
+		reports = append(reports, r)
+	}
+	env.Reports = reports
+	return env, nil
+}
@@ -1,6 +1,8 @@
 package report
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -8,70 +10,90 @@ import (
 	"os"
 	"time"
 
+	"github.com/quanghia24/letsgo/internal/filter"
+	"github.com/quanghia24/letsgo/internal/httpclient"
+	"github.com/quanghia24/letsgo/internal/input"
 	"github.com/quanghia24/letsgo/internal/model"
+	"github.com/quanghia24/letsgo/internal/templates"
 )
 
 type ListReports struct {
 	GeneratedAt     string
+	Run             Envelope // metadata of the run, without reports
+	RunJSON         string
 	Comparisons     []Report
 	ComparisonsJSON string
 }
 
 // Report is the view-model passed to the HTML template
 type Report struct {
-	ProductTitle        string
-	ProductID           int64
-	ImageURL            string
-	ShopID              int64
-	LocalRapidAPITop    []model.ProductItem
-	LocalRapidAPIOrigin []model.ProductItem
-	AliHunterTop        []model.AliHunterProduct
-	AliHunterOrigin     []model.AliHunterProduct
-	AliExpressTop       []model.AliExpressProduct
-	AliExpressOrigin    []model.AliExpressProduct
+	SuggestionID          string `json:",omitempty"` // _id of the suggestion document
+	ProductTitle          string
+	ProductID             int64
+	ImageURL              string
+	ShopID                int64
+	LocalRapidAPITop      []model.ProductItem
+	LocalRapidAPIOrigin   []model.ProductItem
+	LocalRapidAPIExcluded []filter.Exclusion `json:",omitempty"` // candidates dropped by filter rules
+	LocalUnavailable      bool               `json:",omitempty"` // input had no production results, shown as n/a
+	LocalRapidAPIStatus   ProviderStatus     `json:",omitzero"`
+	AliHunterTop          []model.AliHunterProduct
+	AliHunterOrigin       []model.AliHunterProduct
+	AliHunterExcluded     []filter.Exclusion `json:",omitempty"`
+	AliHunterStatus       ProviderStatus     `json:",omitzero"`
+	AliExpressTop         []model.AliExpressProduct
+	AliExpressOrigin      []model.AliExpressProduct
+	AliExpressExcluded    []filter.Exclusion `json:",omitempty"`
+	AliExpressStatus      ProviderStatus     `json:",omitzero"`
+	SellingPrice          float64            `json:",omitempty"` // Shopify selling price used for margins
+	BestMargin            *float64           `json:",omitempty"` // best gross margin across all candidates
+	FetchedAt             time.Time          `json:",omitzero"`  // when the provider data was fetched
 }
 
-func TakeTopProducts(input []model.ProductItem) ([]model.ProductItem, []model.ProductItem) {
-	var filtered []model.ProductItem
-	count := 0
+// TopN is the number of candidates kept per column
+const TopN = 3
 
-	for _, item := range input {
-		if count < 3 && item.ProductMainImageURL != "" {
-			filtered = append(filtered, item)
-			count++
-		}
-	}
-
-	// Return original slice up to its length or 3, whichever is smaller
-	maxLen := len(input)
-	if maxLen > 3 {
-		maxLen = 3
+// Top returns at most the first TopN items
+func Top[T any](items []T) []T {
+	if len(items) > TopN {
+		return items[:TopN]
 	}
-	return filtered, input[:maxLen]
+	return items
 }
 
-// GenerateHTMLReport writes a single HTML file containing all provided report
-func GenerateHTMLReport(reports []Report, outDir string) error {
+// GenerateHTMLReport writes a single HTML file containing all reports of a run.
+// tmplPath overrides the embedded report.tmpl when set.
+func GenerateHTMLReport(env Envelope, outDir, tmplPath string) error {
+	reports := env.Reports
 	// Marshal comparisons to JSON for JavaScript
 	comparisonsJSON, err := json.Marshal(reports)
 	if err != nil {
 		return fmt.Errorf("failed to marshal comparisons to JSON: %w", err)
 	}
+	// The export keeps the run metadata so labeled files stay traceable
+	runJSON, err := json.Marshal(env.Meta())
+	if err != nil {
+		return fmt.Errorf("failed to marshal run metadata to JSON: %w", err)
+	}
 
 	listReports := ListReports{
 		GeneratedAt:     time.Now().Format(time.RFC3339),
+		Run:             env.Meta(),
+		RunJSON:         string(runJSON),
 		Comparisons:     reports,
 		ComparisonsJSON: string(comparisonsJSON),
 	}
 
-	tmplPath := "./internal/templates/report.tmpl"
 	// register template functions
 	funcMap := template.FuncMap{
-		"formatPrice": formatPrice,
+		"formatPrice":  formatPrice,
+		"formatMargin": formatMargin,
+		"marginClass":  marginClass,
+		"sortMargin":   sortMargin,
 	}
-	t, err := template.New("report.tmpl").Funcs(funcMap).ParseFiles(tmplPath)
+	t, err := templates.Load("report.tmpl", tmplPath, funcMap)
 	if err != nil {
-		return fmt.Errorf("failed to parse template %s: %w", tmplPath, err)
+		return err
 	}
 
 	f, err := os.Create(outDir)
@@ -96,46 +118,129 @@ func formatPrice(price string) string {
 	return "$" + price
 }
 
-func GenerateJSONComparisonReport(reports []Report) error {
-	data, err := json.MarshalIndent(reports, "", "  ")
+// format a gross margin as a percentage
+func formatMargin(m *float64) string {
+	if m == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%%", *m*100)
+}
+
+// colour band of a gross margin: healthy, thin or losing money
+func marginClass(m *float64) string {
+	switch {
+	case m == nil:
+		return "margin-none"
+	case *m >= 0.3:
+		return "margin-good"
+	case *m >= 0.1:
+		return "margin-thin"
+	default:
+		return "margin-bad"
+	}
+}
+
+// value used to sort products by best margin, lowest when unknown
+func sortMargin(m *float64) string {
+	if m == nil {
+		return "-1e9"
+	}
+	return fmt.Sprintf("%.4f", *m)
+}
+
+// GenerateJSONComparisonReport writes the reports of a run wrapped in their envelope
+func GenerateJSONComparisonReport(env Envelope, path string) error {
+	if env.Reports == nil {
+		env.Reports = []Report{}
+	}
+	data, err := json.MarshalIndent(env, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal reports to json: %w", err)
 	}
-	if err := os.WriteFile("report.json", data, 0644); err != nil {
+	if err := os.WriteFile(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write json file: %w", err)
 	}
 	return nil
 }
 
+// LoadJSONReport reads the reports of a file accepted by LoadRun
+func LoadJSONReport(path string) ([]Report, error) {
+	env, err := LoadRun(path)
+	if err != nil {
+		return nil, err
+	}
+	return env.Reports, nil
+}
+
+// LoadRun reads a report written by GenerateJSONComparisonReport, exported
+// from the HTML page, or streamed as NDJSON. Older bare arrays load with an
+// envelope holding only the reports.
+func LoadRun(path string) (Envelope, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	trimmed := bytes.TrimSpace(fileBytes)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		// older reports copied Extended JSON wrappers such as {"$numberLong": ...} from the input
+		fileBytes, err = input.NormalizeExtJSON(fileBytes)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		var reports []Report
+		if err := json.Unmarshal(fileBytes, &reports); err != nil {
+			return Envelope{}, fmt.Errorf("failed to unmarshal %s: %w", path, err)
+		}
+		return Envelope{Reports: reports}, nil
+	case json.Valid(trimmed):
+		// a single document: an envelope, or NDJSON holding one report
+		env, err := decodeEnvelope(path, trimmed)
+		if err == nil && env.SchemaVersion == 0 && env.Reports == nil {
+			return parseNDJSON(path, fileBytes)
+		}
+		return env, err
+	default:
+		return parseNDJSON(path, fileBytes)
+	}
+}
+
 type getReviewsCountResponse struct {
 	Data struct {
 		TotalNum int `json:"totalNum"`
 	} `json:"data"`
 }
 
-func GetReviewsCount(productID string) (string, error) {
+// GetReviewsCount looks up the total reviews of an AliExpress product,
+// formatted as "46 ratings". count is empty when the lookup fails, so a
+// failure is not mistaken for a product without reviews.
+func GetReviewsCount(ctx context.Context, productID string) (count string, err error) {
+	call := httpclient.Start(httpclient.ProviderReviews)
+	defer func() { call.End(err) }()
+
 	serviceURL := fmt.Sprintf("https://feedback.aliexpress.com/pc/searchEvaluation.do?productId=%s&page=1", productID)
 
-	req, err := http.NewRequest(http.MethodGet, serviceURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serviceURL, nil)
 	if err != nil {
-		return "0 ratings", fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		return "0 ratings", fmt.Errorf("failed to perform request: %w", err)
+		return "", fmt.Errorf("failed to perform request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check HTTP status code
-	if resp.StatusCode != http.StatusOK {
-		return "0 ratings", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return "", err
 	}
 
 	var data getReviewsCountResponse
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "0 ratings", fmt.Errorf("failed to decode response: %w", err)
+		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	return fmt.Sprintf("%d ratings", data.Data.TotalNum), nil
@@ -0,0 +1,177 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		runID    string  // of the envelope kept, empty for none
+		products []int64 // of the reports, in order
+		wantErr  string  // substring of the error, empty when it loads
+	}{
+		{
+			name:     "bare array",
+			file:     "old.json",
+			data:     `[{"ProductID": 1}, {"ProductID": {"$numberLong": "2"}}]`,
+			products: []int64{1, 2},
+		},
+		{
+			name:     "envelope",
+			file:     "run.json",
+			data:     `{"schema_version": 1, "run_id": "r1", "counts": {"shops": 1, "products": 1}, "reports": [{"ProductID": 3}]}`,
+			runID:    "r1",
+			products: []int64{3},
+		},
+		{
+			name:    "envelope from a newer build",
+			file:    "run.json",
+			data:    `{"schema_version": 99, "reports": []}`,
+			wantErr: "schema version 99",
+		},
+		{
+			name: "ndjson keeps the trailer",
+			file: "run.ndjson",
+			data: `{"schema_version":1,"run_id":"header","counts":{"shops":0,"products":0},"reports":null}
+{"ProductID":1}
+
+{"ProductID":2}
+{"schema_version":1,"run_id":"trailer","counts":{"shops":1,"products":2},"reports":null}
+`,
+			runID:    "trailer",
+			products: []int64{1, 2},
+		},
+		{
+			name:     "ndjson of a single report",
+			file:     "one.ndjson",
+			data:     `{"ProductID":7}` + "\n",
+			products: []int64{7},
+		},
+		{
+			name:     "ndjson without an envelope",
+			file:     "run.jsonl",
+			data:     "{\"ProductID\":1}\n{\"ProductID\":2}\n",
+			products: []int64{1, 2},
+		},
+		{
+			name:     "ndjson cut short by a crash",
+			file:     "run.ndjson",
+			data:     "{\"schema_version\":1,\"run_id\":\"header\",\"counts\":{\"shops\":0,\"products\":0}}\n{\"ProductID\":1}\n{\"ProductID\":2,\"Ima",
+			runID:    "header",
+			products: []int64{1},
+		},
+		{
+			name:    "ndjson with a broken line",
+			file:    "run.ndjson",
+			data:    "{\"ProductID\":1}\n{\"ProductID\":\n{\"ProductID\":3}\n",
+			wantErr: "run.ndjson:2",
+		},
+		{
+			name:    "ndjson from a newer build",
+			file:    "run.ndjson",
+			data:    "{\"schema_version\":99}\n{\"ProductID\":1}\n",
+			wantErr: "schema version 99",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTemp(t, tt.file, tt.data)
+			env, err := LoadRun(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadRun error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.RunID != tt.runID {
+				t.Errorf("run ID %q, want %q", env.RunID, tt.runID)
+			}
+			var products []int64
+			for _, r := range env.Reports {
+				products = append(products, r.ProductID)
+			}
+			if !reflect.DeepEqual(products, tt.products) {
+				t.Errorf("products %v, want %v", products, tt.products)
+			}
+		})
+	}
+}
+
+func TestWriteRun(t *testing.T) {
+	env := NewEnvelope()
+	env.Incomplete = true
+	env.Unprocessed = []ProductRef{{ShopID: 1, ProductID: 3}}
+	for _, r := range []Report{{ShopID: 1, ProductID: 1}, {ShopID: 1, ProductID: 2, SuggestionID: "s2"}} {
+		env.Counts.Add(r)
+		env.Reports = append(env.Reports, r)
+	}
+
+	for _, file := range []string{"run.json", "run.ndjson"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			if err := os.WriteFile(path, []byte("previous report"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteRun(env, path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadRun(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.RunID != env.RunID || !got.Incomplete || !reflect.DeepEqual(got.Unprocessed, env.Unprocessed) {
+				t.Errorf("envelope %+v, want %+v", got.Meta(), env.Meta())
+			}
+			if got.Counts.Shops != 1 || got.Counts.Products != 2 {
+				t.Errorf("counts %+v", got.Counts)
+			}
+			if len(got.Reports) != 2 || got.Reports[1].Key() != "s2" {
+				t.Errorf("reports %+v", got.Reports)
+			}
+			if leftover, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*.tmp")); len(leftover) > 0 {
+				t.Errorf("temporary files left behind: %v", leftover)
+			}
+		})
+	}
+}
+
+func TestAppendNDJSON(t *testing.T) {
+	path := writeTemp(t, "run.ndjson", "{\"ProductID\":1}\n{\"ProductID\":2,\"Ima")
+	w, err := AppendNDJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Report{ProductID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || lines[0] != `{"ProductID":1}` || !strings.Contains(lines[1], `"ProductID":3`) {
+		t.Errorf("file after append:\n%s", data)
+	}
+}
+
+func writeTemp(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quanghia24/letsgo/internal/httpclient"
+)
+
+// Outcome of a provider for one product
+const (
+	StatusOK            = "ok"
+	StatusEmpty         = "empty"          // the provider returned nothing
+	StatusFilteredEmpty = "filtered-empty" // every result was filtered out
+	StatusError         = "error"          // the call failed
+)
+
+// ProviderStatus records how a provider call went, so an API failure can be
+// told apart from an empty answer
+type ProviderStatus struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	LatencyMS  int64  `json:"latency_ms,omitempty"`
+}
+
+// NewProviderStatus classifies a provider call from its error, the number of
+// results it returned and the number left after filtering
+func NewProviderStatus(err error, results, kept int, latency time.Duration) ProviderStatus {
+	s := ProviderStatus{LatencyMS: latency.Milliseconds()}
+	switch {
+	case err != nil:
+		s.Status = StatusError
+		s.Error = err.Error()
+		s.HTTPStatus = httpclient.StatusCode(err)
+	case results == 0:
+		s.Status = StatusEmpty
+	case kept == 0:
+		s.Status = StatusFilteredEmpty
+	default:
+		s.Status = StatusOK
+	}
+	return s
+}
+
+// Failed reports whether the provider call failed
+func (s ProviderStatus) Failed() bool {
+	return s.Status == StatusError
+}
+
+// Failed reports whether any provider call of the report failed
+func (r Report) Failed() bool {
+	return r.AliHunterStatus.Failed() || r.AliExpressStatus.Failed()
+}
+
+// Key identifies the product of a report across runs: the suggestion _id when
+// known, else shop and product ID
+func (r Report) Key() string {
+	return Key(r.SuggestionID, r.ShopID, r.ProductID)
+}
+
+// Key builds the identity used by Report.Key
+func Key(suggestionID string, shopID, productID int64) string {
+	if suggestionID != "" {
+		return suggestionID
+	}
+	return fmt.Sprintf("%d/%d", shopID, productID)
+}
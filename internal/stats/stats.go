@@ -0,0 +1,314 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/quanghia24/letsgo/internal/input"
+	"github.com/quanghia24/letsgo/internal/model"
+)
+
+// Ref points at one suggestion product of the input
+type Ref struct {
+	Where     string // element, line or row of the record
+	ShopID    int64
+	ProductID int64
+}
+
+// Failure is a record that could not be decoded
+type Failure struct {
+	Where string
+	Err   string
+}
+
+// CountMismatch is a shop group whose product_count disagrees with its
+// suggestion_products
+type CountMismatch struct {
+	Where    string
+	ShopID   int64
+	Declared int
+	Actual   int
+}
+
+// Stats summarises an input file and the problems found in it
+type Stats struct {
+	Path            string
+	Format          string
+	Shops           int
+	Products        int
+	LocalItems      int
+	Statuses        map[string]int
+	Platforms       map[string]int // platform of the local Products
+	Types           map[string]int // type of the local Products
+	MissingImages   []Ref
+	DuplicateImages map[string][]Ref
+	CountMismatches []CountMismatch
+	Failures        []Failure
+
+	shops  map[string]bool
+	images map[string][]Ref
+}
+
+// OK reports whether every record was decoded
+func (s *Stats) OK() bool {
+	return len(s.Failures) == 0
+}
+
+// Collect decodes an input file record by record, so one bad record is
+// reported instead of failing the whole file. JSON arrays, NDJSON and CSV
+// inputs are accepted.
+func Collect(path string) (*Stats, error) {
+	s := &Stats{
+		Path:            path,
+		Statuses:        make(map[string]int),
+		Platforms:       make(map[string]int),
+		Types:           make(map[string]int),
+		DuplicateImages: make(map[string][]Ref),
+		shops:           make(map[string]bool),
+		images:          make(map[string][]Ref),
+	}
+
+	var err error
+	switch {
+	case input.IsCSV(path):
+		s.Format = "csv"
+		err = s.collectCSV(path)
+	case input.IsNDJSON(path):
+		s.Format = "ndjson"
+		err = s.collectNDJSON(path)
+	default:
+		s.Format = "json"
+		err = s.collectJSON(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Shops = len(s.shops)
+	for url, refs := range s.images {
+		if len(refs) > 1 {
+			s.DuplicateImages[url] = refs
+		}
+	}
+	return s, nil
+}
+
+func (s *Stats) collectJSON(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return fmt.Errorf("%s is not a JSON array: %w", path, err)
+	}
+	// a bad Extended JSON value only fails its own element
+	for i, raw := range elems {
+		where := fmt.Sprintf("element %d", i+1)
+		raw, err := input.NormalizeExtJSON(raw)
+		if err != nil {
+			s.fail(where, err)
+			continue
+		}
+		s.addGroup(where, raw)
+	}
+	return nil
+}
+
+func (s *Stats) collectNDJSON(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	for line := 1; ; line++ {
+		data, readErr := r.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("cannot read %s: %w", path, readErr)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			s.addLine(fmt.Sprintf("line %d", line), data)
+		}
+		if readErr != nil { // EOF
+			return nil
+		}
+	}
+}
+
+func (s *Stats) addLine(where string, data []byte) {
+	data, err := input.NormalizeExtJSON(data)
+	if err != nil {
+		s.fail(where, err)
+		return
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		s.fail(where, err)
+		return
+	}
+	if _, isGroup := probe["suggestion_products"]; isGroup {
+		s.addGroup(where, data)
+		return
+	}
+	var p model.SuggestionProduct
+	if err := json.Unmarshal(data, &p); err != nil {
+		s.fail(where, err)
+		return
+	}
+	s.shops[strconv.FormatInt(p.ShopID, 10)] = true
+	s.addProduct(where, p)
+}
+
+func (s *Stats) collectCSV(path string) error {
+	return input.StreamCSV(path, func(line int, shop string, p model.SuggestionProduct, err error) error {
+		where := fmt.Sprintf("row %d", line)
+		if err != nil {
+			s.fail(where, err)
+			return nil
+		}
+		p.ShopID = input.CSVShopID(shop)
+		s.shops[strconv.FormatInt(p.ShopID, 10)] = true
+		s.addProduct(where, p)
+		return nil
+	})
+}
+
+func (s *Stats) addGroup(where string, raw []byte) {
+	var g struct {
+		ShopID             int64             `json:"shop_id"`
+		Shop               model.Shop        `json:"shop"`
+		ProductCount       int               `json:"product_count"`
+		SuggestionProducts []json.RawMessage `json:"suggestion_products"`
+	}
+	if err := json.Unmarshal(raw, &g); err != nil {
+		s.fail(where, err)
+		return
+	}
+	shopID := g.ShopID
+	if shopID == 0 {
+		shopID = g.Shop.ShopID
+	}
+	s.shops[strconv.FormatInt(shopID, 10)] = true
+	if g.ProductCount != len(g.SuggestionProducts) {
+		s.CountMismatches = append(s.CountMismatches, CountMismatch{
+			Where: where, ShopID: shopID, Declared: g.ProductCount, Actual: len(g.SuggestionProducts),
+		})
+	}
+
+	for i, rawProduct := range g.SuggestionProducts {
+		productWhere := fmt.Sprintf("%s, product %d", where, i+1)
+		var p model.SuggestionProduct
+		if err := json.Unmarshal(rawProduct, &p); err != nil {
+			s.fail(productWhere, err)
+			continue
+		}
+		if p.ShopID == 0 {
+			p.ShopID = shopID
+		}
+		s.addProduct(productWhere, p)
+	}
+}
+
+func (s *Stats) addProduct(where string, p model.SuggestionProduct) {
+	s.Products++
+	s.Statuses[orNone(p.Status)]++
+
+	ref := Ref{Where: where, ShopID: p.ShopID, ProductID: p.ProductID}
+	if p.ImageURL == "" {
+		s.MissingImages = append(s.MissingImages, ref)
+	} else {
+		s.images[p.ImageURL] = append(s.images[p.ImageURL], ref)
+	}
+
+	for _, item := range p.Products {
+		s.LocalItems++
+		s.Platforms[orNone(item.Platform)]++
+		s.Types[orNone(item.Type)]++
+	}
+}
+
+func (s *Stats) fail(where string, err error) {
+	s.Failures = append(s.Failures, Failure{Where: where, Err: err.Error()})
+}
+
+func orNone(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
+
+// WriteText prints the statistics followed by every problem found
+func WriteText(w io.Writer, s *Stats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "File:\t%s (%s)\n", s.Path, s.Format)
+	fmt.Fprintf(tw, "Shops:\t%d\n", s.Shops)
+	fmt.Fprintf(tw, "Products:\t%d\n", s.Products)
+	fmt.Fprintf(tw, "Local results:\t%d\n", s.LocalItems)
+	writeCounts(tw, "Status", s.Statuses)
+	writeCounts(tw, "Platform", s.Platforms)
+	writeCounts(tw, "Type", s.Types)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nMissing image URLs: %d\n", len(s.MissingImages))
+	for _, r := range s.MissingImages {
+		fmt.Fprintf(w, "  %s: product %d (shop %d)\n", r.Where, r.ProductID, r.ShopID)
+	}
+
+	urls := make([]string, 0, len(s.DuplicateImages))
+	for url := range s.DuplicateImages {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	fmt.Fprintf(w, "\nDuplicate image URLs: %d\n", len(urls))
+	for _, url := range urls {
+		fmt.Fprintf(w, "  %s\n", url)
+		for _, r := range s.DuplicateImages[url] {
+			fmt.Fprintf(w, "    %s: product %d (shop %d)\n", r.Where, r.ProductID, r.ShopID)
+		}
+	}
+
+	fmt.Fprintf(w, "\nProduct count mismatches: %d\n", len(s.CountMismatches))
+	for _, m := range s.CountMismatches {
+		fmt.Fprintf(w, "  %s: shop %d declares %d products, has %d\n", m.Where, m.ShopID, m.Declared, m.Actual)
+	}
+
+	fmt.Fprintf(w, "\nRecords that fail to parse: %d\n", len(s.Failures))
+	for _, f := range s.Failures {
+		fmt.Fprintf(w, "  %s: %s\n", f.Where, f.Err)
+	}
+	return nil
+}
+
+// writeCounts prints a distribution, most frequent first
+func writeCounts(w io.Writer, label string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for i, k := range keys {
+		name := ""
+		if i == 0 {
+			name = label + ":"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\n", name, k, counts[k])
+	}
+}
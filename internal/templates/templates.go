@@ -0,0 +1,41 @@
+// Package templates holds the HTML page templates, embedded in the binary so
+// it runs from any directory.
+package templates
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+)
+
+//go:embed *.tmpl
+var files embed.FS
+
+// Load parses the embedded template name (e.g. "report.tmpl"), or the file
+// at override when set, for custom themes
+func Load(name, override string, funcs template.FuncMap) (*template.Template, error) {
+	var data []byte
+	var err error
+	if override != "" {
+		data, err = os.ReadFile(override)
+	} else {
+		data, err = files.ReadFile(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", source(name, override), err)
+	}
+
+	t, err := template.New(name).Funcs(funcs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", source(name, override), err)
+	}
+	return t, nil
+}
+
+func source(name, override string) string {
+	if override != "" {
+		return override
+	}
+	return name
+}